@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+	"neuro-dev/config"
+	"neuro-dev/db"
+	"neuro-dev/services"
+)
+
+// commands are maintenance tasks run as `neuro-dev <command>` instead of starting the server
+var commands = map[string]func() error{
+	"rotate-token-key": rotateTokenKey,
+	"migrate":          migrate,
+	"config":           printConfig,
+}
+
+// printConfig prints the effective settings after environment expansion and overrides, with secrets masked
+func printConfig() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(config.Root{Settings: *cfg.Redacted()})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("# %s\n%s", config.Path(), b)
+	return nil
+}
+
+// migrate runs `neuro-dev migrate up|down [steps]|status` against the configured database.
+// down reverts one migration unless steps is given.
+func migrate() error {
+	if len(os.Args) < 3 {
+		return errors.New("usage: migrate up|down [steps]|status")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	dbConn, err := db.Init(cfg)
+	if err != nil {
+		return err
+	}
+
+	switch os.Args[2] {
+	case "up":
+		tokens, err := services.NewTokenCipher(cfg.Security.TokenKey, cfg.Security.PreviousTokenKeys)
+		if err != nil {
+			return err
+		}
+		applied, err := db.MigrateUp(dbConn, services.MigrationData{ConfigDir: filepath.Dir(config.Path()), Tokens: tokens})
+		for _, m := range applied {
+			fmt.Printf("Applied %s_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(os.Args) > 3 {
+			if steps, err = strconv.Atoi(os.Args[3]); err != nil || steps < 1 {
+				return fmt.Errorf("invalid steps: %s", os.Args[3])
+			}
+		}
+		reverted, err := db.MigrateDown(dbConn, steps)
+		for _, m := range reverted {
+			fmt.Printf("Reverted %s_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("No applied migrations to revert")
+		}
+	case "status":
+		states, err := db.MigrationStatus(dbConn)
+		if err != nil {
+			return err
+		}
+		for _, st := range states {
+			applied := "pending"
+			if st.Applied {
+				applied = "applied " + st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%s_%s\t%s\n", st.Version, st.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate action: %s (expected up, down or status)", os.Args[2])
+	}
+	return nil
+}
+
+// rotateTokenKey re-wraps all model tokens with security.token_key and encrypts plain text ones.
+// To rotate, move the old key to security.previous_token_keys, set the new key and run this command;
+// the old key can be removed afterwards.
+func rotateTokenKey() error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	tokens, err := services.NewTokenCipher(cfg.Security.TokenKey, cfg.Security.PreviousTokenKeys)
+	if err != nil {
+		return err
+	}
+	dbConn, err := db.Init(cfg)
+	if err != nil {
+		return err
+	}
+	svc := services.NewService(dbConn, nil)
+	svc.Tokens = tokens
+	updated, err := svc.EncryptModelTokens()
+	if err != nil {
+		return fmt.Errorf("rotate token key failed: %w", err)
+	}
+	fmt.Printf("Re-encrypted %d model tokens\n", updated)
+	return nil
+}
@@ -1,8 +1,13 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,6 +18,9 @@ import (
 //   logger:
 //   jwt:
 //   database:
+//   llm:
+//   security:
+//   admin:
 
 type Settings struct {
 	Application Application `yaml:"application"`
@@ -20,62 +28,134 @@ type Settings struct {
 	JWT         JWT         `yaml:"jwt"`
 	Database    Database    `yaml:"database"`
 	LLM         LLM         `yaml:"llm"`
+	Security    Security    `yaml:"security"`
+	Admin       Admin       `yaml:"admin"`
 }
 
+// DefaultShutdownTimeout applies when application.shutdowntimeout is not set
+const DefaultShutdownTimeout = 30 * time.Second
+
 type Application struct {
-	Mode         string `yaml:"mode"`
-	Host         string `yaml:"host"`
-	Name         string `yaml:"name"`
-	Port         int    `yaml:"port"`
-	ReadTimeout  int    `yaml:"readtimeout"`
-	WriteTimeout int    `yaml:"writertimeout"`
-	EnableDP     bool   `yaml:"enabledp"`
+	Mode            string            `yaml:"mode"`
+	Host            string            `yaml:"host"`
+	Name            string            `yaml:"name"`
+	Port            int               `yaml:"port"`            // PORT also overrides it
+	MetricsPort     int               `yaml:"metricsport"`     // internal listener for /metrics, 0 disables it
+	ReadTimeout     int               `yaml:"readtimeout"`     // seconds, 0 disables the timeout
+	WriteTimeout    int               `yaml:"writertimeout"`   // seconds, 0 disables the timeout
+	ShutdownTimeout int               `yaml:"shutdowntimeout"` // seconds to drain requests and checkpoint tasks on SIGTERM
+	EnableDP        bool              `yaml:"enabledp"`
+	DataScopes      map[string]string `yaml:"datascopes"` // organization role -> all, organization, department or own
+}
+
+// ShutdownDuration returns the shutdown timeout, DefaultShutdownTimeout when it is not set
+func (a Application) ShutdownDuration() time.Duration {
+	if a.ShutdownTimeout > 0 {
+		return time.Duration(a.ShutdownTimeout) * time.Second
+	}
+	return DefaultShutdownTimeout
 }
 
 type Logger struct {
-	Path     string `yaml:"path"`
-	Stdout   string `yaml:"stdout"`
-	Level    string `yaml:"level"`
-	EnableDB bool   `yaml:"enableddb"`
+	Path       string `yaml:"path"`
+	Stdout     string `yaml:"stdout"` // file writes to path, anything else to stdout
+	Level      string `yaml:"level"`
+	Format     string `yaml:"format"`     // text or json, defaults to text
+	MaxSize    int    `yaml:"maxsize"`    // megabytes before the log file is rotated
+	MaxBackups int    `yaml:"maxbackups"` // rotated files to keep
+	MaxAge     int    `yaml:"maxage"`     // days to keep rotated files
+	Compress   bool   `yaml:"compress"`   // gzip rotated files
+	EnableDB   bool   `yaml:"enableddb"`
 }
 
 type JWT struct {
-	Secret  string `yaml:"secret"`
-	Timeout int    `yaml:"timeout"`
+	Secret         string `yaml:"secret" secret:"true"`
+	Timeout        int    `yaml:"timeout"`        // access token lifetime in seconds
+	RefreshTimeout int    `yaml:"refreshtimeout"` // refresh token lifetime in seconds
+}
+
+// Admin is the account created on first start when there are no users
+type Admin struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password" secret:"true"` // empty generates a password and logs it
 }
 
 type Database struct {
 	Driver   string `yaml:"driver"`
-	Source   string `yaml:"source"`
+	Source   string `yaml:"source" secret:"true"`
 	User     string `yaml:"user"`
-	Password string `yaml:"password"`
+	Password string `yaml:"password" secret:"true"`
 }
 
 type LLM struct {
-	Provider string `yaml:"provider"`
-	ApiKey   string `yaml:"api_key"`
-	BaseURL  string `yaml:"base_url"`
-	Model    string `yaml:"model"`
-	Timeout  int    `yaml:"timeout"`
+	Provider    string `yaml:"provider"`
+	ApiKey      string `yaml:"api_key" secret:"true"`
+	BaseURL     string `yaml:"base_url"`
+	Model       string `yaml:"model"`
+	Timeout     int    `yaml:"timeout"`
+	CassetteDir string `yaml:"cassette_dir"` // where recorded LLM interactions are stored
+}
+
+type Security struct {
+	TokenKey          string   `yaml:"token_key" secret:"true"`           // base64 encoded 32 byte master key for model tokens; NEURO_TOKEN_KEY also overrides it
+	PreviousTokenKeys []string `yaml:"previous_token_keys" secret:"true"` // retired master keys, only used to decrypt until rotate-token-key has run
 }
 
 type Root struct {
 	Settings Settings `yaml:"settings"`
 }
 
-// Load reads YAML configuration from the default path ./config/settings.yml unless overridden by NEURO_SETTINGS_PATH
-func Load() (*Settings, error) {
-	path := os.Getenv("NEURO_SETTINGS_PATH")
-	if path == "" {
-		path = "./config/settings.yml"
+// Path returns the settings file: NEURO_SETTINGS_PATH when set, otherwise config/settings.yml in the
+// working directory or next to the executable
+func Path() string {
+	if path := os.Getenv("NEURO_SETTINGS_PATH"); path != "" {
+		return path
+	}
+	path := filepath.Join("config", "settings.yml")
+	if fileExists(path) {
+		return path
+	}
+	if exe, err := os.Executable(); err == nil {
+		if p := filepath.Join(filepath.Dir(exe), path); fileExists(p) {
+			return p
+		}
 	}
+	return path
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Load reads the settings file, expands ${VAR} and ${VAR:-default} references in its values, applies
+// NEURO_<SECTION>_<KEY> overrides and validates the result. Unknown keys are rejected.
+func Load() (*Settings, error) {
+	path := Path()
 	b, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("read settings.yml failed: %w", err)
+		return nil, fmt.Errorf("read settings failed: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("unmarshal %s failed: %w", path, err)
+	}
+	expandNode(&doc)
+	expanded, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("expand %s failed: %w", path, err)
 	}
 	var r Root
-	if err := yaml.Unmarshal(b, &r); err != nil {
-		return nil, fmt.Errorf("unmarshal settings.yml failed: %w", err)
+	dec := yaml.NewDecoder(bytes.NewReader(expanded))
+	dec.KnownFields(true)
+	if err := dec.Decode(&r); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unmarshal %s failed: %w", path, err)
+	}
+	if err := applyEnv(&r.Settings); err != nil {
+		return nil, fmt.Errorf("apply environment overrides failed: %w", err)
+	}
+	if err := r.Settings.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid settings in %s:\n%w", path, err)
 	}
 	return &r.Settings, nil
 }
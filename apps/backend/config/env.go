@@ -0,0 +1,139 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the environment variables that override settings, e.g. NEURO_DATABASE_SOURCE
+const EnvPrefix = "NEURO_"
+
+// legacyEnv maps override variables to the names they had before EnvPrefix naming, which still work
+var legacyEnv = map[string]string{
+	"NEURO_SECURITY_TOKEN_KEY": "NEURO_TOKEN_KEY",
+	"NEURO_APPLICATION_PORT":   "PORT",
+}
+
+// envRef matches ${VAR} and ${VAR:-default}
+var envRef = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces ${VAR} with the value of VAR, or with default in ${VAR:-default} when VAR is unset or empty
+func expandEnv(s string) string {
+	return envRef.ReplaceAllStringFunc(s, func(ref string) string {
+		m := envRef.FindStringSubmatch(ref)
+		if v := os.Getenv(m[1]); v != "" {
+			return v
+		}
+		return m[3]
+	})
+}
+
+// expandNode expands environment references in every scalar value of a YAML document. Expanding after
+// parsing keeps values that contain YAML syntax, such as passwords with ': ' or '#', intact.
+func expandNode(n *yaml.Node) {
+	if n.Kind == yaml.ScalarNode && strings.Contains(n.Value, "${") {
+		n.Value = expandEnv(n.Value)
+		// Resolve the type again so that port: ${PORT:-8000} decodes into an int
+		n.Tag, n.Style = "", 0
+	}
+	for _, c := range n.Content {
+		expandNode(c)
+	}
+}
+
+// EnvName returns the override variable of a setting, e.g. EnvName("llm", "api_key") is NEURO_LLM_API_KEY
+func EnvName(section, key string) string {
+	return EnvPrefix + strings.ToUpper(section) + "_" + strings.ToUpper(key)
+}
+
+// applyEnv overrides settings with non-empty NEURO_<SECTION>_<KEY> variables.
+// Lists are comma separated and maps are comma separated key=value pairs.
+func applyEnv(s *Settings) error {
+	return eachSetting(reflect.ValueOf(s).Elem(), func(section, key string, field reflect.Value, _ reflect.StructField) error {
+		name := EnvName(section, key)
+		v := os.Getenv(name)
+		if v == "" && legacyEnv[name] != "" {
+			v = os.Getenv(legacyEnv[name])
+		}
+		if v == "" {
+			return nil
+		}
+		if err := setValue(field, v); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// eachSetting calls fn for every leaf setting with its section and key from the yaml tags
+func eachSetting(settings reflect.Value, fn func(section, key string, field reflect.Value, sf reflect.StructField) error) error {
+	st := settings.Type()
+	for i := 0; i < st.NumField(); i++ {
+		section := yamlKey(st.Field(i))
+		sv := settings.Field(i)
+		for j := 0; j < sv.NumField(); j++ {
+			sf := sv.Type().Field(j)
+			if err := fn(section, yamlKey(sf), sv.Field(j), sf); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func yamlKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(f.Name)
+	}
+	return name
+}
+
+func setValue(field reflect.Value, v string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(v)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", v)
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, expected true or false", v)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		m := map[string]string{}
+		for _, pair := range strings.Split(v, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("invalid entry %q, expected key=value", pair)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+		field.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("unsupported setting type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+const (
+	// DefaultJWTSecret is the sample secret earlier settings files shipped with
+	DefaultJWTSecret = "go-admin"
+	// MinJWTSecretLength is the shortest secret accepted outside dev, 256 bits for HS256
+	MinJWTSecretLength = 32
+)
+
+var (
+	modes      = []string{"dev", "test", "prod"}
+	logLevels  = []string{"trace", "debug", "info", "warn", "error", "fatal"}
+	logFormats = []string{"text", "json"}
+	drivers    = []string{"postgres", "mysql", "sqlite3", "sqlite"}
+	// dataScopes mirrors the scopes understood by services.DataScopeRules
+	dataScopes = []string{"all", "organization", "department", "own"}
+)
+
+// Validate checks the settings and reports every problem at once, naming the setting and its override variable
+func (s *Settings) Validate() error {
+	var errs []error
+	check := func(ok bool, section, key, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("settings.%s.%s (%s): %s", section, key, EnvName(section, key), fmt.Sprintf(format, args...)))
+		}
+	}
+	oneOf := func(section, key, value string, allowed []string) {
+		check(value == "" || contains(allowed, value), section, key, "unsupported value %q, expected one of %s", value, strings.Join(allowed, ", "))
+	}
+
+	a := s.Application
+	oneOf("application", "mode", a.Mode, modes)
+	check(a.Port >= 0 && a.Port <= 65535, "application", "port", "%d is not a valid port", a.Port)
+	check(a.MetricsPort >= 0 && a.MetricsPort <= 65535, "application", "metricsport", "%d is not a valid port", a.MetricsPort)
+	check(a.MetricsPort == 0 || a.MetricsPort != a.Port, "application", "metricsport", "must differ from application.port so metrics stay off the API")
+	check(a.ReadTimeout >= 0, "application", "readtimeout", "must not be negative")
+	check(a.WriteTimeout >= 0, "application", "writertimeout", "must not be negative")
+	check(a.ShutdownTimeout >= 0, "application", "shutdowntimeout", "must not be negative")
+	for role, scope := range a.DataScopes {
+		check(contains(dataScopes, strings.ToLower(strings.TrimSpace(scope))), "application", "datascopes",
+			"unsupported scope %q for %s, expected one of %s", scope, role, strings.Join(dataScopes, ", "))
+	}
+
+	oneOf("logger", "level", s.Logger.Level, logLevels)
+	oneOf("logger", "format", s.Logger.Format, logFormats)
+	check(s.Logger.Stdout != "file" || s.Logger.Path != "", "logger", "path", "is required when logger.stdout is file")
+	check(s.Logger.MaxSize >= 0 && s.Logger.MaxBackups >= 0 && s.Logger.MaxAge >= 0, "logger", "maxsize",
+		"maxsize, maxbackups and maxage must not be negative")
+
+	// Only dev may run with the sample secret or without one, which generates a random secret at startup
+	if a.Mode != "dev" {
+		check(s.JWT.Secret != "", "jwt", "secret", "is required when application.mode is not dev")
+		check(s.JWT.Secret == "" || s.JWT.Secret != DefaultJWTSecret, "jwt", "secret", "must not be the sample secret %q when application.mode is not dev", DefaultJWTSecret)
+		check(s.JWT.Secret == "" || len(s.JWT.Secret) >= MinJWTSecretLength, "jwt", "secret", "must be at least %d bytes when application.mode is not dev", MinJWTSecretLength)
+	}
+	check(s.JWT.Timeout >= 0, "jwt", "timeout", "must not be negative")
+	check(s.JWT.RefreshTimeout >= 0, "jwt", "refreshtimeout", "must not be negative")
+
+	check(s.Database.Driver != "", "database", "driver", "is required, expected one of %s", strings.Join(drivers, ", "))
+	oneOf("database", "driver", s.Database.Driver, drivers)
+	check(s.Database.Source != "", "database", "source", "is required")
+
+	check(s.LLM.Timeout >= 0, "llm", "timeout", "must not be negative")
+
+	// Without a key model tokens are stored in plain text, which is only acceptable outside production
+	check(a.Mode != "prod" || strings.TrimSpace(s.Security.TokenKey) != "", "security", "token_key", "is required when application.mode is prod")
+
+	return errors.Join(errs...)
+}
+
+func contains(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted replaces a configured secret so it can be shown
+const redacted = "******"
+
+// Redacted returns a copy of the settings with every field tagged secret:"true" masked
+func (s *Settings) Redacted() *Settings {
+	c := *s
+	_ = eachSetting(reflect.ValueOf(&c).Elem(), func(_, _ string, field reflect.Value, sf reflect.StructField) error {
+		if sf.Tag.Get("secret") != "true" {
+			return nil
+		}
+		switch field.Kind() {
+		case reflect.String:
+			if field.String() != "" {
+				field.SetString(redacted)
+			}
+		case reflect.Slice:
+			masked := make([]string, field.Len())
+			for i := range masked {
+				masked[i] = redacted
+			}
+			field.Set(reflect.ValueOf(masked))
+		}
+		return nil
+	})
+	return &c
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// validSettings passes validation in dev mode
+func validSettings() *Settings {
+	return &Settings{
+		Application: Application{Mode: "dev", Port: 8080},
+		JWT:         JWT{Secret: DefaultJWTSecret},
+		Database:    Database{Driver: "sqlite3", Source: "data/neuro.db"},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(s *Settings)
+		want   string // substring of the error, empty for valid settings
+	}{
+		{"dev defaults", func(s *Settings) {}, ""},
+		{"metrics on the api port", func(s *Settings) {
+			s.Application.MetricsPort = 8080
+		}, "settings.application.metricsport (NEURO_APPLICATION_METRICSPORT): must differ from application.port"},
+		{"prod without token key", func(s *Settings) {
+			s.Application.Mode = "prod"
+		}, "settings.security.token_key (NEURO_SECURITY_TOKEN_KEY): is required when application.mode is prod"},
+		{"test without token key", func(s *Settings) {
+			s.Application.Mode = "test"
+			s.JWT.Secret = strings.Repeat("s", MinJWTSecretLength)
+		}, ""},
+		{"dev without jwt secret", func(s *Settings) {
+			s.JWT.Secret = ""
+		}, ""},
+		{"prod", func(s *Settings) {
+			s.Application.Mode = "prod"
+			s.JWT.Secret = strings.Repeat("s", MinJWTSecretLength)
+			s.Security.TokenKey = "a2V5"
+		}, ""},
+		{"prod without jwt secret", func(s *Settings) {
+			s.Application.Mode = "prod"
+			s.JWT.Secret = ""
+		}, "settings.jwt.secret (NEURO_JWT_SECRET): is required when application.mode is not dev"},
+		{"prod with sample jwt secret", func(s *Settings) {
+			s.Application.Mode = "prod"
+		}, `must not be the sample secret "go-admin"`},
+		{"test with short jwt secret", func(s *Settings) {
+			s.Application.Mode = "test"
+			s.JWT.Secret = strings.Repeat("s", MinJWTSecretLength-1)
+		}, "must be at least 32 bytes"},
+		{"unknown driver", func(s *Settings) {
+			s.Database.Driver = "oracle"
+		}, "settings.database.driver"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validSettings()
+			tt.modify(s)
+			err := s.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	s := validSettings()
+	s.Database.Password = "db-password"
+	r := s.Redacted()
+	if r.Database.Source != redacted || r.Database.Password != redacted || r.JWT.Secret != redacted {
+		t.Errorf("secrets left in %+v", r)
+	}
+	if r.Database.Driver != "sqlite3" || s.Database.Source != "data/neuro.db" {
+		t.Errorf("Redacted changed other settings or the original: %+v %+v", r.Database, s.Database)
+	}
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"neuro-dev/models"
+	"neuro-dev/services"
+)
+
+// listAPIKeys returns the caller's keys; admins get every key with ?all=true
+func (s *Server) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	claims := currentClaims(r)
+	userID := claims.UserID()
+	if claims.IsAdmin() && r.URL.Query().Get("all") == "true" {
+		userID = 0
+	}
+	keys, err := s.Svc.ListAPIKeys(userID)
+	if err != nil {
+		s.sendError(w, "Failed to load API keys", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, keys)
+}
+
+// createAPIKey issues a key for the caller. The key is only part of this response.
+func (s *Server) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	key, err := s.Svc.CreateAPIKey(currentClaims(r).UserID(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrForbidden) {
+			s.sendError(w, "Admin role required for admin keys", http.StatusForbidden)
+			return
+		}
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.InfoContext(r.Context(), "Created API key", "key_id", key.ID, "name", key.Name, "scope", key.Scope, "username", currentClaims(r).Username)
+	s.sendResponse(w, key)
+}
+
+func (s *Server) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.sendError(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+	claims := currentClaims(r)
+	key, err := s.Svc.RevokeAPIKey(uint(id), claims.UserID(), claims.IsAdmin())
+	if err != nil {
+		s.sendAccessError(w, err, "API key not found")
+		return
+	}
+	slog.InfoContext(r.Context(), "Revoked API key", "key_id", key.ID, "username", claims.Username)
+	s.sendResponse(w, key)
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"neuro-dev/models"
+)
+
+// audit records a change made by the caller. A failure is logged and does not fail the request,
+// since the change itself has already been committed.
+func (s *Server) audit(r *http.Request, action, entityType, entityID, projectID string, before, after interface{}) {
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		ProjectID:  projectID,
+		RequestID:  requestID(r),
+	}
+	if claims := currentClaims(r); claims != nil {
+		entry.ActorID = claims.UserID()
+		entry.Actor = claims.Username
+		entry.APIKeyID = claims.KeyID
+	}
+	if err := s.Svc.RecordAudit(entry, before, after); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to audit change", "action", action, "entity_type", entityType, "entity_id", entityID, "error", err)
+	}
+}
+
+// listAuditLogs returns audit entries, newest first.
+// Query params: entity_type, entity_id, project_id, actor, action, request_id,
+// from, to (YYYY-MM-DD, to is inclusive), limit (default 100), offset.
+func (s *Server) listAuditLogs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := models.AuditQuery{
+		EntityType: q.Get("entity_type"),
+		EntityID:   q.Get("entity_id"),
+		ProjectID:  q.Get("project_id"),
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		RequestID:  q.Get("request_id"),
+	}
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			s.sendError(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		query.From = &from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			s.sendError(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+		query.To = &to
+	}
+	for name, target := range map[string]*int{"limit": &query.Limit, "offset": &query.Offset} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				s.sendError(w, "Invalid "+name, http.StatusBadRequest)
+				return
+			}
+			*target = n
+		}
+	}
+
+	logs, total, err := s.Svc.ListAuditLogs(requestScope(r), query)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, map[string]interface{}{"items": logs, "total": total})
+}
@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"neuro-dev/models"
+	"neuro-dev/services"
+)
+
+// Authentication and user handlers
+
+func (s *Server) login(w http.ResponseWriter, r *http.Request) {
+	var req models.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	tokens, err := s.Svc.Login(req.Username, req.Password)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			s.sendError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		slog.WarnContext(r.Context(), "Login failed", "username", req.Username, "error", err)
+		s.sendError(w, "Failed to sign in", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, tokens)
+}
+
+func (s *Server) refreshToken(w http.ResponseWriter, r *http.Request) {
+	var req models.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	tokens, err := s.Svc.Refresh(req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			s.sendError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		s.sendError(w, "Failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, tokens)
+}
+
+// getCurrentUser returns the account of the access token
+func (s *Server) getCurrentUser(w http.ResponseWriter, r *http.Request) {
+	var user models.User
+	if err := s.Svc.DB.First(&user, currentClaims(r).UserID()).Error; err != nil {
+		s.sendError(w, "User not found", http.StatusNotFound)
+		return
+	}
+	s.sendResponse(w, user)
+}
+
+func (s *Server) changePassword(w http.ResponseWriter, r *http.Request) {
+	var req models.ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	tokens, err := s.Svc.ChangePassword(currentClaims(r).UserID(), req.OldPassword, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCredentials) {
+			s.sendError(w, "Current password is wrong", http.StatusForbidden)
+			return
+		}
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Other sessions can no longer refresh; this one continues with the new pair
+	s.sendResponse(w, tokens)
+}
+
+func (s *Server) listUsers(w http.ResponseWriter, r *http.Request) {
+	var users []models.User
+	if err := s.Svc.DB.Order("id").Find(&users).Error; err != nil {
+		s.sendError(w, "Failed to load users", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, users)
+}
+
+func (s *Server) setUserDepartment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.sendError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	var req models.SetDepartmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	user, err := s.Svc.SetUserDepartment(uint(id), req.Department)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.sendError(w, "User not found", http.StatusNotFound)
+			return
+		}
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	slog.InfoContext(r.Context(), "Changed user department", "user_id", user.ID, "department", user.Department)
+	s.sendResponse(w, user)
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	user, err := s.Svc.CreateUser(req)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, user)
+}
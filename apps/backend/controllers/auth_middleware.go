@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+	"neuro-dev/models"
+	"neuro-dev/services"
+)
+
+type contextKey string
+
+const (
+	claimsKey contextKey = "claims"
+	scopeKey  contextKey = "scope"
+)
+
+// publicPaths can be called without an access token
+var publicPaths = map[string]bool{
+	"/api/auth/login":   true,
+	"/api/auth/refresh": true,
+	"/api/health":       true,
+}
+
+// authenticate rejects requests without a valid access token or API key and stores its claims and the
+// caller's organization scope in the request context.
+// Browsers cannot set headers on WebSocket handshakes, so /ws routes also accept ?access_token=.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token := bearerToken(r)
+		if token == "" && strings.HasPrefix(r.URL.Path, "/ws/") {
+			token = r.URL.Query().Get("access_token")
+		}
+		if token == "" {
+			s.sendError(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		var claims *services.Claims
+		var err error
+		if services.IsAPIKey(token) {
+			claims, err = s.Svc.ParseAPIKey(token)
+		} else {
+			claims, err = s.Svc.ParseAccessToken(token)
+		}
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidToken) {
+				s.sendError(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			slog.WarnContext(r.Context(), "Authentication failed", "error", err)
+			s.sendError(w, "Failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		if claims.IsAPIKey() && !apiKeyAllows(claims.KeyScope, r) {
+			s.sendError(w, "API key scope "+claims.KeyScope+" does not allow this request", http.StatusForbidden)
+			return
+		}
+		scope, err := s.Svc.UserScope(claims.UserID(), claims.IsAdmin())
+		if err != nil {
+			s.sendError(w, "Failed to load organizations", http.StatusInternalServerError)
+			return
+		}
+		ctx := context.WithValue(r.Context(), claimsKey, claims)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, scopeKey, scope)))
+	})
+}
+
+// adminOnly restricts a handler to administrators
+func (s *Server) adminOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims := currentClaims(r)
+		if claims == nil || !claims.IsAdmin() {
+			s.sendError(w, "Admin role required", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// sessionOnly restricts a handler to signed-in users, so an API key cannot manage credentials
+func (s *Server) sessionOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if claims := currentClaims(r); claims == nil || claims.IsAPIKey() {
+			s.sendError(w, "A user session is required", http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// apiKeyAllows checks a request against the scope of an API key: read keys may only read,
+// project-write keys may also change projects and tasks, admin keys are unrestricted
+func apiKeyAllows(scope string, r *http.Request) bool {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return true
+	}
+	switch scope {
+	case models.APIKeyScopeAdmin:
+		return true
+	case models.APIKeyScopeProjectWrite:
+		return strings.HasPrefix(r.URL.Path, "/api/projects") || strings.HasPrefix(r.URL.Path, "/api/tasks")
+	}
+	return false
+}
+
+// currentClaims returns the claims of the authenticated caller, nil on public routes
+func currentClaims(r *http.Request) *services.Claims {
+	claims, _ := r.Context().Value(claimsKey).(*services.Claims)
+	return claims
+}
+
+// requestScope returns the organization scope of the authenticated caller.
+// Public routes have no caller; they get a scope that sees nothing.
+func requestScope(r *http.Request) *services.Scope {
+	if scope, ok := r.Context().Value(scopeKey).(*services.Scope); ok {
+		return scope
+	}
+	return &services.Scope{Roles: map[uint]string{}}
+}
+
+// authorizeProject checks that the caller has at least role on a project and otherwise writes the error.
+// It reports whether the handler may continue.
+func (s *Server) authorizeProject(w http.ResponseWriter, r *http.Request, projectID, role string) bool {
+	if _, err := s.Svc.AuthorizeProject(requestScope(r), projectID, role); err != nil {
+		s.sendAccessError(w, err, "Project not found")
+		return false
+	}
+	return true
+}
+
+// authorizeTask checks role on the project of a task, like authorizeProject
+func (s *Server) authorizeTask(w http.ResponseWriter, r *http.Request, taskID, role string) bool {
+	if _, err := s.Svc.AuthorizeTask(requestScope(r), taskID, role); err != nil {
+		s.sendAccessError(w, err, "Task not found")
+		return false
+	}
+	return true
+}
+
+// sendAccessError reports a failed Authorize* call: hidden or missing resources as 404, missing roles as 403
+func (s *Server) sendAccessError(w http.ResponseWriter, err error, notFound string) {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		s.sendError(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		s.sendError(w, notFound, http.StatusNotFound)
+	default:
+		slog.Error("Access check failed", "error", err)
+		s.sendError(w, "Failed to check permissions", http.StatusInternalServerError)
+	}
+}
+
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
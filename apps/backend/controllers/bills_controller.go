@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"neuro-dev/models"
+	"neuro-dev/services"
+)
+
+// Bill-related handlers
+
+// importBills accepts a multipart form with fields: vendor, file and optional project_id.
+// Without project_id lines are mapped by the rules of all organizations, so only admins may do that.
+func (s *Server) importBills(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		s.sendError(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	vendor := r.FormValue("vendor")
+	if vendor == "" {
+		s.sendError(w, "Vendor is required", http.StatusBadRequest)
+		return
+	}
+	projectID := r.FormValue("project_id")
+	if projectID != "" {
+		if !s.authorizeProject(w, r, projectID, models.OrgRoleManager) {
+			return
+		}
+	} else if !requestScope(r).Admin {
+		s.sendError(w, "Admin role required to import bills without project_id", http.StatusForbidden)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		s.sendError(w, "CSV file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	result, err := s.Svc.WithContext(r.Context()).ImportBills(vendor, file, projectID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to import bills", "vendor", vendor, "error", err)
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, result)
+}
+
+// listBills returns the bills of the caller's projects; unmapped bills are only visible to admins
+func (s *Server) listBills(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := s.Svc.DB.Model(&models.Bill{}).Scopes(requestScope(r).Bills)
+	if projectID := q.Get("project_id"); projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+	if vendor := q.Get("vendor"); vendor != "" {
+		code, err := services.NormalizeVendor(vendor)
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query = query.Where("vendor = ?", code)
+	}
+	if q.Get("unmapped") == "true" {
+		query = query.Where("project_id = ? OR project_id IS NULL", "")
+	}
+	if importID := q.Get("import_id"); importID != "" {
+		query = query.Where("import_id = ?", importID)
+	}
+	var bills []models.Bill
+	if err := query.Order("period_start desc").Find(&bills).Error; err != nil {
+		s.sendError(w, "Failed to load bills", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, bills)
+}
+
+func (s *Server) remapBills(w http.ResponseWriter, r *http.Request) {
+	mapped, err := s.Svc.RemapBills()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to remap bills", "error", err)
+		s.sendError(w, "Failed to remap bills", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, map[string]interface{}{"mapped": mapped})
+}
+
+func (s *Server) listBillRules(w http.ResponseWriter, r *http.Request) {
+	query := s.Svc.DB.Order("priority asc, id asc").Scopes(requestScope(r).Bills)
+	var rules []models.BillRule
+	if err := query.Find(&rules).Error; err != nil {
+		s.sendError(w, "Failed to load bill rules", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, rules)
+}
+
+func (s *Server) createBillRule(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateBillRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.ProjectID == "" {
+		s.sendError(w, "Project ID is required", http.StatusBadRequest)
+		return
+	}
+	if req.TagKey == "" && req.Pattern == "" {
+		s.sendError(w, "Either tag_key or pattern is required", http.StatusBadRequest)
+		return
+	}
+	if req.Field != "" && req.Field != "service_type" && req.Field != "resource" {
+		s.sendError(w, "Field must be service_type or resource", http.StatusBadRequest)
+		return
+	}
+	if !s.authorizeProject(w, r, req.ProjectID, models.OrgRoleManager) {
+		return
+	}
+	vendor := ""
+	if req.Vendor != "" {
+		code, err := services.NormalizeVendor(req.Vendor)
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		vendor = code
+	}
+	rule := models.BillRule{
+		ProjectID: req.ProjectID,
+		Vendor:    vendor,
+		TagKey:    req.TagKey,
+		TagValue:  req.TagValue,
+		Field:     req.Field,
+		Pattern:   req.Pattern,
+		Priority:  req.Priority,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.Svc.DB.Create(&rule).Error; err != nil {
+		s.sendError(w, "Failed to create bill rule", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, rule)
+}
+
+func (s *Server) deleteBillRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		s.sendError(w, "Invalid rule ID", http.StatusBadRequest)
+		return
+	}
+	var rule models.BillRule
+	if err := s.Svc.DB.First(&rule, id).Error; err != nil {
+		s.sendError(w, "Bill rule not found", http.StatusNotFound)
+		return
+	}
+	if !s.authorizeProject(w, r, rule.ProjectID, models.OrgRoleManager) {
+		return
+	}
+	if err := s.Svc.DB.Delete(&rule).Error; err != nil {
+		s.sendError(w, "Failed to delete bill rule", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, map[string]string{"message": "Bill rule deleted successfully"})
+}
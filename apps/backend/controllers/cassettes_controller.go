@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"neuro-dev/models"
+	"neuro-dev/services"
+)
+
+// Cassette handlers: record a project's LLM interactions and replay them for debugging
+
+func (s *Server) listCassettes(w http.ResponseWriter, r *http.Request) {
+	names, err := s.Svc.ListCassettes(r.URL.Query().Get("project_id"))
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, names)
+}
+
+func (s *Server) getCassette(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	cassette, err := s.Svc.LoadNamedCassette(vars["name"])
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.sendResponse(w, cassette)
+}
+
+func (s *Server) startRecording(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID := vars["id"]
+	var project models.Project
+	if err := s.Svc.DB.Select("id").First(&project, "id = ?", projectID).Error; err != nil {
+		s.sendError(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	name, err := s.Svc.StartRecording(projectID)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.sendResponse(w, map[string]string{"cassette": name})
+}
+
+func (s *Server) stopRecording(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if err := s.Svc.StopRecording(vars["id"]); err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, map[string]string{"message": "Recording stopped"})
+}
+
+// replayProject re-runs task generation of a project from a cassette and returns the tasks without saving them.
+// Body: {"cassette": "<name>"}. A prompt that no longer matches the recording is reported as 409.
+func (s *Server) replayProject(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID := vars["id"]
+	var req struct {
+		Cassette string `json:"cassette"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var project models.Project
+	if err := s.Svc.DB.First(&project, "id = ?", projectID).Error; err != nil {
+		s.sendError(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	cassette, err := s.Svc.LoadNamedCassette(req.Cassette)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	tasks, remaining, err := s.Svc.WithContext(r.Context()).ReplayProject(&project, cassette)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Replay of project failed", "project_id", projectID, "cassette", req.Cassette, "error", err)
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrCassetteMismatch) || errors.Is(err, services.ErrCassetteExhausted) {
+			status = http.StatusConflict
+		}
+		s.sendError(w, err.Error(), status)
+		return
+	}
+	s.sendResponse(w, map[string]interface{}{
+		"cassette":  req.Cassette,
+		"tasks":     tasks,
+		"remaining": remaining, // recorded interactions not consumed by the replay, e.g. task decompositions
+	})
+}
@@ -2,12 +2,19 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"log/slog"
 	"net/http"
+	"neuro-dev/config"
 	"neuro-dev/models"
+	"neuro-dev/services"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 )
 
 // Config-related handlers
@@ -30,15 +37,25 @@ func (s *Server) getPhases(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// getRoles reads RoleConfig.json from the backend config directory and returns its JSON content
+// getRoles returns the active role prompts of a locale (?locale=en for English). Before any role prompt
+// has been seeded it falls back to RoleConfig.json in the backend config directory.
 func (s *Server) getRoles(w http.ResponseWriter, r *http.Request) {
-	// Determine config path relative to working directory
-	// Default to ./config/RoleConfig.json, which matches apps/backend/config/RoleConfig.json when run from apps/backend
-	path := filepath.Clean("./config/RoleConfig.json")
-	if _, err := os.Stat(path); err != nil {
-		// Fallback to absolute path in repo if not found (useful during development)
-		path = filepath.Clean("D:/code-work/go/work-space/neuro-dev/apps/backend/config/RoleConfig.json")
+	locale, err := services.NormalizeLocale(r.URL.Query().Get("locale"))
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	active, err := s.Svc.ActiveRolePrompts(locale)
+	if err != nil {
+		s.sendError(w, "Failed to load role prompts", http.StatusInternalServerError)
+		return
 	}
+	if len(active) > 0 {
+		s.sendResponse(w, active)
+		return
+	}
+	// The role configs sit next to the settings file
+	path := services.ConfigPath(filepath.Dir(config.Path()), "RoleConfig.json", locale)
 	b, err := os.ReadFile(path)
 	if err != nil {
 		s.sendError(w, "failed to read RoleConfig.json", http.StatusInternalServerError)
@@ -52,16 +69,15 @@ func (s *Server) getRoles(w http.ResponseWriter, r *http.Request) {
 	s.sendResponse(w, roles)
 }
 
+// getModels returns the shared models and the models of the caller's organizations
 func (s *Server) getModels(w http.ResponseWriter, r *http.Request) {
-	// Get models from database
-	var modelList []models.Model
-	if err := s.Svc.DB.Find(&modelList).Error; err != nil {
+	// If no models in database, initialize with default shared models
+	var total int64
+	if err := s.Svc.DB.Model(&models.Model{}).Count(&total).Error; err != nil {
 		s.sendError(w, "Failed to fetch models from database", http.StatusInternalServerError)
 		return
 	}
-
-	// If no models in database, initialize with default models
-	if len(modelList) == 0 {
+	if total == 0 {
 		defaultModels := []string{"GPT_3_5_TURBO", "GPT_4", "GPT_4_TURBO", "GPT_4O", "GPT_4O_MINI"}
 		for _, modelName := range defaultModels {
 			model := models.Model{
@@ -70,10 +86,19 @@ func (s *Server) getModels(w http.ResponseWriter, r *http.Request) {
 				IsCustom: false,
 			}
 			s.Svc.DB.Create(&model)
-			modelList = append(modelList, model)
 		}
 	}
 
+	// Get models from database
+	var modelList []models.Model
+	if err := requestScope(r).Models(s.Svc.DB).Find(&modelList).Error; err != nil {
+		s.sendError(w, "Failed to fetch models from database", http.StatusInternalServerError)
+		return
+	}
+	for i := range modelList {
+		s.Svc.MaskModelTokens(&modelList[i])
+	}
+
 	s.sendResponse(w, modelList)
 }
 
@@ -84,6 +109,13 @@ func (s *Server) createModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Shared models are managed by admins, organization models by the organization's managers
+	scope := requestScope(r)
+	if req.OrganizationID == nil && !scope.Admin || req.OrganizationID != nil && !scope.Can(*req.OrganizationID, models.OrgRoleManager) {
+		s.sendError(w, services.ErrForbidden.Error(), http.StatusForbidden)
+		return
+	}
+
 	// Check if model already exists
 	var existing models.Model
 	if err := s.Svc.DB.Where("name = ?", req.Name).First(&existing).Error; err == nil {
@@ -91,17 +123,51 @@ func (s *Server) createModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	currency := models.DefaultCurrency
+	if req.Currency != "" {
+		c, err := services.NormalizeCurrency(req.Currency)
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		currency = c
+	}
+
+	if !services.IsOutputMode(req.StructuredOutput) {
+		s.sendError(w, "Invalid structured_output, expected text, json_object, json_schema or function", http.StatusBadRequest)
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(req.Provider))
+	if !services.IsProvider(provider) {
+		s.sendError(w, "Invalid provider, expected "+strings.Join(services.Providers, ", "), http.StatusBadRequest)
+		return
+	}
+	if provider != "" && !services.SupportsOutputMode(provider, req.StructuredOutput) {
+		s.sendError(w, fmt.Sprintf("Provider %s does not support structured_output %s", provider, req.StructuredOutput), http.StatusBadRequest)
+		return
+	}
+
 	model := models.Model{
-		Name:     req.Name,
-		BaseURL:  req.BaseURL,
-		Token:    req.Token,
-		IsCustom: true,
+		Name:             req.Name,
+		Provider:         provider,
+		BaseURL:          req.BaseURL,
+		IsCustom:         true,
+		OrganizationID:   req.OrganizationID,
+		InputPrice:       req.InputPrice,
+		OutputPrice:      req.OutputPrice,
+		Currency:         currency,
+		StructuredOutput: req.StructuredOutput,
+	}
+	if err := s.Svc.SetModelToken(&model, req.Token); err != nil {
+		s.sendError(w, "Failed to encrypt model token", http.StatusInternalServerError)
+		return
 	}
 
 	if err := s.Svc.DB.Create(&model).Error; err != nil {
 		s.sendError(w, "Failed to create model", http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, models.AuditCreate, models.AuditEntityModel, strconv.Itoa(int(model.ID)), "", nil, model)
 
 	s.sendResponse(w, model)
 }
@@ -120,28 +186,68 @@ func (s *Server) updateModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var model models.Model
-	if err := s.Svc.DB.First(&model, id).Error; err != nil {
-		s.sendError(w, "Model not found", http.StatusNotFound)
+	found, err := s.Svc.AuthorizeModel(requestScope(r), uint(id), models.OrgRoleManager)
+	if err != nil {
+		s.sendAccessError(w, err, "Model not found")
 		return
 	}
+	model := *found
+	before := model
 
 	// Update fields if provided
 	if req.Name != "" {
 		model.Name = req.Name
 	}
+	if req.Provider != "" {
+		provider := strings.ToLower(strings.TrimSpace(req.Provider))
+		if !services.IsProvider(provider) {
+			s.sendError(w, "Invalid provider, expected "+strings.Join(services.Providers, ", "), http.StatusBadRequest)
+			return
+		}
+		model.Provider = provider
+	}
 	if req.BaseURL != "" {
 		model.BaseURL = req.BaseURL
 	}
 	if req.Token != "" {
-		model.Token = req.Token
+		if err := s.Svc.SetModelToken(&model, req.Token); err != nil {
+			s.sendError(w, "Failed to encrypt model token", http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.StructuredOutput != "" {
+		if !services.IsOutputMode(req.StructuredOutput) {
+			s.sendError(w, "Invalid structured_output, expected text, json_object, json_schema or function", http.StatusBadRequest)
+			return
+		}
+		model.StructuredOutput = req.StructuredOutput
+	}
+	if model.Provider != "" && !services.SupportsOutputMode(model.Provider, model.StructuredOutput) {
+		s.sendError(w, fmt.Sprintf("Provider %s does not support structured_output %s", model.Provider, model.StructuredOutput), http.StatusBadRequest)
+		return
+	}
+	if req.InputPrice != nil {
+		model.InputPrice = *req.InputPrice
+	}
+	if req.OutputPrice != nil {
+		model.OutputPrice = *req.OutputPrice
+	}
+	if req.Currency != "" {
+		currency, err := services.NormalizeCurrency(req.Currency)
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		model.Currency = currency
 	}
 
 	if err := s.Svc.DB.Save(&model).Error; err != nil {
 		s.sendError(w, "Failed to update model", http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, models.AuditUpdate, models.AuditEntityModel, strconv.Itoa(id), "", before, model)
 
+	s.Svc.MaskModelTokens(&model)
 	s.sendResponse(w, model)
 }
 
@@ -161,17 +267,28 @@ func (s *Server) updateModelToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var model models.Model
-	if err := s.Svc.DB.Where("name = ?", modelName).First(&model).Error; err != nil {
+	var named models.Model
+	if err := s.Svc.DB.Select("id").Where("name = ?", modelName).First(&named).Error; err != nil {
 		s.sendError(w, "Model not found", http.StatusNotFound)
 		return
 	}
+	found, err := s.Svc.AuthorizeModel(requestScope(r), named.ID, models.OrgRoleManager)
+	if err != nil {
+		s.sendAccessError(w, err, "Model not found")
+		return
+	}
+	model := *found
+	before := model
 
-	model.Token = req.Token
+	if err := s.Svc.SetModelToken(&model, req.Token); err != nil {
+		s.sendError(w, "Failed to encrypt model token", http.StatusInternalServerError)
+		return
+	}
 	if err := s.Svc.DB.Save(&model).Error; err != nil {
 		s.sendError(w, "Failed to update model token", http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, models.AuditUpdate, models.AuditEntityModel, strconv.Itoa(int(model.ID)), "", before, model)
 
 	s.sendResponse(w, model)
 }
@@ -184,16 +301,44 @@ func (s *Server) deleteModel(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var model models.Model
-	if err := s.Svc.DB.First(&model, id).Error; err != nil {
-		s.sendError(w, "Model not found", http.StatusNotFound)
+	model, err := s.Svc.AuthorizeModel(requestScope(r), uint(id), models.OrgRoleOwner)
+	if err != nil {
+		s.sendAccessError(w, err, "Model not found")
 		return
 	}
 
-	if err := s.Svc.DB.Delete(&model).Error; err != nil {
+	if err := s.Svc.DB.Delete(model).Error; err != nil {
 		s.sendError(w, "Failed to delete model", http.StatusInternalServerError)
 		return
 	}
+	s.audit(r, models.AuditDelete, models.AuditEntityModel, strconv.Itoa(id), "", *model, nil)
 
 	s.sendResponse(w, map[string]string{"message": "Model deleted successfully"})
 }
+
+// checkModel sends a minimal completion to the model and reports latency and a classified error.
+// The result is also stored on the model as its last check.
+func (s *Server) checkModel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		s.sendError(w, "Invalid model ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := s.Svc.AuthorizeModel(requestScope(r), uint(id), models.OrgRoleManager); err != nil {
+		s.sendAccessError(w, err, "Model not found")
+		return
+	}
+	result, err := s.Svc.CheckModel(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.sendError(w, "Model not found", http.StatusNotFound)
+			return
+		}
+		slog.ErrorContext(r.Context(), "Failed to check model", "model_id", id, "error", err)
+		s.sendError(w, "Failed to check model", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, result)
+}
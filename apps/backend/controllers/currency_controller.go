@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"neuro-dev/models"
+)
+
+// Exchange rate handlers
+func (s *Server) listExchangeRates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := s.Svc.DB.Model(&models.ExchangeRate{})
+	if base := q.Get("base"); base != "" {
+		query = query.Where("base_currency = ?", strings.ToUpper(base))
+	}
+	if quote := q.Get("quote"); quote != "" {
+		query = query.Where("quote_currency = ?", strings.ToUpper(quote))
+	}
+	var rates []models.ExchangeRate
+	if err := query.Order("effective_date desc, base_currency, quote_currency").Find(&rates).Error; err != nil {
+		s.sendError(w, "Failed to load exchange rates", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, rates)
+}
+
+func (s *Server) createExchangeRate(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateExchangeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.BaseCurrency == "" || req.QuoteCurrency == "" {
+		s.sendError(w, "Base and quote currency are required", http.StatusBadRequest)
+		return
+	}
+	date := time.Now()
+	if req.EffectiveDate != "" {
+		d, err := time.Parse("2006-01-02", req.EffectiveDate)
+		if err != nil {
+			s.sendError(w, "Invalid effective_date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		date = d
+	}
+	rate := models.ExchangeRate{
+		BaseCurrency:  req.BaseCurrency,
+		QuoteCurrency: req.QuoteCurrency,
+		Rate:          req.Rate,
+		EffectiveDate: date,
+		Source:        "manual",
+	}
+	if err := s.Svc.SaveExchangeRate(&rate); err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, rate)
+}
+
+// importExchangeRates accepts a multipart CSV upload in the "file" field
+func (s *Server) importExchangeRates(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(8 << 20); err != nil {
+		s.sendError(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		s.sendError(w, "CSV file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	saved, err := s.Svc.ImportExchangeRates(file)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to import exchange rates", "saved", saved, "error", err)
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, map[string]interface{}{"imported": saved})
+}
+
+func (s *Server) deleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		s.sendError(w, "Invalid exchange rate ID", http.StatusBadRequest)
+		return
+	}
+	var rate models.ExchangeRate
+	if err := s.Svc.DB.First(&rate, id).Error; err != nil {
+		s.sendError(w, "Exchange rate not found", http.StatusNotFound)
+		return
+	}
+	if err := s.Svc.DB.Delete(&rate).Error; err != nil {
+		s.sendError(w, "Failed to delete exchange rate", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, map[string]string{"message": "Exchange rate deleted successfully"})
+}
@@ -0,0 +1,142 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"neuro-dev/models"
+	"neuro-dev/services"
+)
+
+// Organization and membership handlers
+
+// organizationID parses {id} and checks that the caller has at least role in the organization
+func (s *Server) organizationID(w http.ResponseWriter, r *http.Request, role string) (uint, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.sendError(w, "Invalid organization ID", http.StatusBadRequest)
+		return 0, false
+	}
+	scope := requestScope(r)
+	if _, member := scope.Roles[uint(id)]; !member && !scope.Admin {
+		s.sendError(w, "Organization not found", http.StatusNotFound)
+		return 0, false
+	}
+	if !scope.Can(uint(id), role) {
+		s.sendError(w, services.ErrForbidden.Error(), http.StatusForbidden)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func (s *Server) listOrganizations(w http.ResponseWriter, r *http.Request) {
+	orgs, err := s.Svc.ListOrganizations(requestScope(r))
+	if err != nil {
+		s.sendError(w, "Failed to load organizations", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, orgs)
+}
+
+// createOrganization creates an organization with the caller as its owner
+func (s *Server) createOrganization(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateOrganizationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	org, err := s.Svc.CreateOrganization(requestScope(r), req.Name)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, org)
+}
+
+func (s *Server) deleteOrganization(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.organizationID(w, r, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+	if err := s.Svc.DeleteOrganization(id); err != nil {
+		s.sendError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	s.sendResponse(w, map[string]string{"message": "Organization deleted successfully"})
+}
+
+func (s *Server) listOrganizationMembers(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.organizationID(w, r, models.OrgRoleViewer)
+	if !ok {
+		return
+	}
+	members, err := s.Svc.ListMembers(id)
+	if err != nil {
+		s.sendError(w, "Failed to load members", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, members)
+}
+
+// setOrganizationMember adds a member (POST) or changes a member's role (PUT /members/{userId})
+func (s *Server) setOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.organizationID(w, r, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+	var req models.OrganizationMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if v := mux.Vars(r)["userId"]; v != "" {
+		userID, err := strconv.Atoi(v)
+		if err != nil {
+			s.sendError(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		req.UserID = uint(userID)
+	}
+	if req.UserID == 0 && req.Username == "" {
+		s.sendError(w, "user_id or username is required", http.StatusBadRequest)
+		return
+	}
+	member, err := s.Svc.SetMember(id, req)
+	if err != nil {
+		if errors.Is(err, services.ErrLastOwner) {
+			s.sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, member)
+}
+
+func (s *Server) removeOrganizationMember(w http.ResponseWriter, r *http.Request) {
+	id, ok := s.organizationID(w, r, models.OrgRoleOwner)
+	if !ok {
+		return
+	}
+	userID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil {
+		s.sendError(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if err := s.Svc.RemoveMember(id, uint(userID)); err != nil {
+		switch {
+		case errors.Is(err, services.ErrLastOwner):
+			s.sendError(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			s.sendError(w, "Member not found", http.StatusNotFound)
+		default:
+			s.sendError(w, "Failed to remove member", http.StatusInternalServerError)
+		}
+		return
+	}
+	s.sendResponse(w, map[string]string{"message": "Member removed successfully"})
+}
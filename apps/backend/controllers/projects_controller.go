@@ -2,8 +2,9 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sort"
 	"time"
@@ -11,40 +12,45 @@ import (
 	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 	"neuro-dev/models"
+	"neuro-dev/services"
 )
 
 // Project-related handlers
 func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
-	// Load from DB with tasks
+	// Load the caller's projects from DB with tasks
 	var projects []models.Project
-	if err := s.Svc.DB.Preload("Tasks").Find(&projects).Error; err != nil {
+	if err := s.Svc.DB.Scopes(requestScope(r).Projects).Preload("Tasks").Find(&projects).Error; err != nil {
 		s.sendError(w, "Failed to load projects", http.StatusInternalServerError)
 		return
 	}
+	rates, err := s.Svc.LoadRateTable()
+	if err != nil {
+		s.sendError(w, "Failed to load exchange rates", http.StatusInternalServerError)
+		return
+	}
 	// Sort by updated_at desc for stable ordering
 	sort.Slice(projects, func(i, j int) bool { return projects[i].UpdatedAt.After(projects[j].UpdatedAt) })
 	// Optionally compute progress based on tasks completion
 	for i := range projects {
 		p := &projects[i]
-		total := len(p.Tasks)
+		// Parents only roll up their subtasks, so completion is counted on leaf tasks
+		leaves := services.LeafTasks(p.Tasks)
+		total := len(leaves)
 		if total == 0 {
 			p.Progress = 0
 			p.EstimatedCost = 0
 			continue
 		}
 		completed := 0
-		estimatedCost := 0.0
-		for _, t := range p.Tasks {
+		for _, t := range leaves {
 			if t.Status == "completed" {
 				completed++
 			}
-			// Sum up estimated costs, treating null/zero as 0
-			estimatedCost += t.EstimatedCost
 		}
 		// Store percentage in Project.Progress if field exists (not persisted here)
 		p.Progress = (completed * 100) / total
-		// Store calculated estimated cost
-		p.EstimatedCost = estimatedCost
+		// Sum up estimated costs in the project's reporting currency
+		p.EstimatedCost = s.Svc.ProjectEstimatedCost(p, rates)
 	}
 	s.sendResponse(w, projects)
 }
@@ -59,35 +65,61 @@ func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	currency, err := services.NormalizeCurrency(req.ReportingCurrency)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	locale, err := services.NormalizeLocale(req.Locale)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	org, ok := s.projectOrganization(w, r, req.OrganizationID, req.Model)
+	if !ok {
+		return
+	}
+
+	scope := requestScope(r)
 	projectID := s.Svc.NextProjectID()
 	project := &models.Project{
-		ID:           projectID,
-		Name:         req.Name,
-		Description:  req.Description,
-		Organization: req.Organization,
-		Model:        req.Model,
-		Status:       "created",
-		Vendors:      req.Vendors,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-		Progress:     0,
-		Tasks:        make([]models.Task, 0),
+		ID:                projectID,
+		Name:              req.Name,
+		Description:       req.Description,
+		Organization:      org.Name,
+		OrganizationID:    org.ID,
+		CreatedBy:         scope.UserID,
+		Department:        scope.Department,
+		Model:             req.Model,
+		Status:            "created",
+		Vendors:           req.Vendors,
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+		Progress:          0,
+		Tasks:             make([]models.Task, 0),
+		ReportingCurrency: currency,
+		Locale:            locale,
 	}
 
 	// Persist project and tasks in a transaction
 	if err := s.Svc.DB.Transaction(func(tx *gorm.DB) error {
 		// Create the project first without tasks
 		projectWithoutTasks := &models.Project{
-			ID:           project.ID,
-			Name:         project.Name,
-			Description:  project.Description,
-			Organization: project.Organization,
-			Model:        project.Model,
-			Status:       project.Status,
-			Vendors:      project.Vendors,
-			CreatedAt:    project.CreatedAt,
-			UpdatedAt:    project.UpdatedAt,
-			Progress:     project.Progress,
+			ID:                project.ID,
+			Name:              project.Name,
+			Description:       project.Description,
+			Organization:      project.Organization,
+			OrganizationID:    project.OrganizationID,
+			CreatedBy:         project.CreatedBy,
+			Department:        project.Department,
+			Model:             project.Model,
+			Status:            project.Status,
+			Vendors:           project.Vendors,
+			CreatedAt:         project.CreatedAt,
+			UpdatedAt:         project.UpdatedAt,
+			Progress:          project.Progress,
+			ReportingCurrency: project.ReportingCurrency,
+			Locale:            project.Locale,
 		}
 		if err := tx.Create(projectWithoutTasks).Error; err != nil {
 			return err
@@ -95,36 +127,90 @@ func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
 
 		return nil
 	}); err != nil {
-		log.Printf("Failed to create project %s: %v", projectID, err)
+		slog.ErrorContext(r.Context(), "Failed to create project", "project_id", projectID, "error", err)
 		s.sendError(w, "Failed to create project", http.StatusInternalServerError)
 		return
 	}
 
 	// Keep in-memory map optionally for runtime use
 	s.Svc.Projects[projectID] = project
+	s.audit(r, models.AuditCreate, models.AuditEntityProject, projectID, projectID, nil, project)
 	s.sendResponse(w, project)
 }
 
+// projectOrganization resolves the organization a project is created in or moved to and checks that the
+// caller manages it and that the model is available to it. Without an ID the caller's only managed
+// organization is used.
+func (s *Server) projectOrganization(w http.ResponseWriter, r *http.Request, orgID uint, model string) (*models.Organization, bool) {
+	scope := requestScope(r)
+	if orgID == 0 {
+		for id := range scope.Roles {
+			if scope.Can(id, models.OrgRoleManager) {
+				if orgID != 0 {
+					orgID = 0
+					break
+				}
+				orgID = id
+			}
+		}
+		if orgID == 0 {
+			s.sendError(w, "organization_id is required", http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	var org models.Organization
+	if err := s.Svc.DB.First(&org, orgID).Error; err != nil {
+		s.sendError(w, "Organization not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !scope.Can(org.ID, models.OrgRoleManager) {
+		s.sendError(w, services.ErrForbidden.Error(), http.StatusForbidden)
+		return nil, false
+	}
+	if model != "" {
+		available, err := s.Svc.ModelAvailable(model, org.ID)
+		if err != nil {
+			s.sendError(w, "Failed to check model", http.StatusInternalServerError)
+			return nil, false
+		}
+		if !available {
+			s.sendError(w, fmt.Sprintf("Model %s is not available to organization %s", model, org.Name), http.StatusBadRequest)
+			return nil, false
+		}
+	}
+	return &org, true
+}
+
 func (s *Server) getProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleViewer) {
+		return
+	}
 	var project models.Project
 	if err := s.Svc.DB.Preload("Tasks").First(&project, "id = ?", projectID).Error; err != nil {
 		s.sendError(w, "Project not found", http.StatusNotFound)
 		return
 	}
-	// Calculate estimated cost from tasks
-	estimatedCost := 0.0
-	for _, t := range project.Tasks {
-		estimatedCost += t.EstimatedCost
+	// Calculate estimated cost from tasks in the project's reporting currency
+	rates, err := s.Svc.LoadRateTable()
+	if err != nil {
+		s.sendError(w, "Failed to load exchange rates", http.StatusInternalServerError)
+		return
+	}
+	project.EstimatedCost = s.Svc.ProjectEstimatedCost(&project, rates)
+	if r.URL.Query().Get("tree") == "true" {
+		project.Tasks = services.BuildTaskTree(project.Tasks)
 	}
-	project.EstimatedCost = estimatedCost
 	s.sendResponse(w, project)
 }
 
 func (s *Server) updateProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleManager) {
+		return
+	}
 
 	var req models.CreateProjectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -144,14 +230,43 @@ func (s *Server) updateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := project
+
+	// Moving a project needs manager on the target organization too
+	orgID := req.OrganizationID
+	if orgID == 0 {
+		orgID = project.OrganizationID
+	}
+	org, ok := s.projectOrganization(w, r, orgID, req.Model)
+	if !ok {
+		return
+	}
+
 	// Update project fields
 	updateData := map[string]interface{}{
-		"name":         req.Name,
-		"description":  req.Description,
-		"organization": req.Organization,
-		"model":        req.Model,
-		"vendors":      req.Vendors,
-		"updated_at":   time.Now(),
+		"name":            req.Name,
+		"description":     req.Description,
+		"organization":    org.Name,
+		"organization_id": org.ID,
+		"model":           req.Model,
+		"vendors":         req.Vendors,
+		"updated_at":      time.Now(),
+	}
+	if req.ReportingCurrency != "" {
+		currency, err := services.NormalizeCurrency(req.ReportingCurrency)
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateData["reporting_currency"] = currency
+	}
+	if req.Locale != "" {
+		locale, err := services.NormalizeLocale(req.Locale)
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updateData["locale"] = locale
 	}
 
 	// Update in database
@@ -170,26 +285,33 @@ func (s *Server) updateProject(w http.ResponseWriter, r *http.Request) {
 	if _, exists := s.Svc.Projects[projectID]; exists {
 		s.Svc.Projects[projectID].Name = req.Name
 		s.Svc.Projects[projectID].Description = req.Description
-		s.Svc.Projects[projectID].Organization = req.Organization
+		s.Svc.Projects[projectID].Organization = org.Name
+		s.Svc.Projects[projectID].OrganizationID = org.ID
 		s.Svc.Projects[projectID].Model = req.Model
 		s.Svc.Projects[projectID].Vendors = req.Vendors
+		s.Svc.Projects[projectID].Locale = project.Locale
 		s.Svc.Projects[projectID].UpdatedAt = time.Now()
 	}
 
+	s.audit(r, models.AuditUpdate, models.AuditEntityProject, projectID, projectID, before, project)
 	s.sendResponse(w, project)
 }
 
 func (s *Server) getProjectStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleViewer) {
+		return
+	}
 	var project models.Project
 	if err := s.Svc.DB.Preload("Tasks").First(&project, "id = ?", projectID).Error; err != nil {
 		s.sendError(w, "Project not found", http.StatusNotFound)
 		return
 	}
-	totalTasks := len(project.Tasks)
+	leaves := services.LeafTasks(project.Tasks)
+	totalTasks := len(leaves)
 	completed := 0
-	for _, t := range project.Tasks {
+	for _, t := range leaves {
 		if t.Status == "completed" {
 			completed++
 		}
@@ -211,6 +333,9 @@ func (s *Server) getProjectStatus(w http.ResponseWriter, r *http.Request) {
 func (s *Server) startProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleManager) {
+		return
+	}
 	var project models.Project
 	if err := s.Svc.DB.Preload("Tasks").First(&project, "id = ?", projectID).Error; err != nil {
 		s.sendError(w, "Project not found", http.StatusNotFound)
@@ -220,13 +345,17 @@ func (s *Server) startProject(w http.ResponseWriter, r *http.Request) {
 		s.sendError(w, "Project is already running", http.StatusBadRequest)
 		return
 	}
+	before := project
 
 	// Generate tasks if they don't exist yet
 	if len(project.Tasks) == 0 {
-		generatedTasks := s.Svc.GenerateTasksFromDescription(project.Description, project.Model, project.Vendors)
+		svc := s.Svc.WithContext(r.Context())
+		generatedTasks := svc.GenerateTasksFromDescription(&project)
+		svc.CalibrateTasks(generatedTasks, project.Model)
 		// assign ProjectID and reset status for generated tasks
 		for i := range generatedTasks {
 			generatedTasks[i].ProjectID = projectID
+			generatedTasks[i].Currency = project.ReportingCurrency
 		}
 		project.Tasks = generatedTasks
 
@@ -246,10 +375,13 @@ func (s *Server) startProject(w http.ResponseWriter, r *http.Request) {
 			}
 			return nil
 		}); err != nil {
-			log.Printf("Failed to create tasks for project %s: %v", projectID, err)
+			slog.ErrorContext(r.Context(), "Failed to create tasks for project", "project_id", projectID, "error", err)
 			s.sendError(w, "Failed to create tasks for project", http.StatusInternalServerError)
 			return
 		}
+		for _, task := range project.Tasks {
+			s.audit(r, models.AuditCreate, models.AuditEntityTask, task.ID, projectID, nil, task)
+		}
 	}
 
 	// optionally keep memory map for execution progress
@@ -283,12 +415,16 @@ func (s *Server) startProject(w http.ResponseWriter, r *http.Request) {
 	project.Status = "running"
 	project.UpdatedAt = time.Now()
 	_ = s.Svc.DB.Model(&models.Project{}).Where("id = ?", projectID).Updates(map[string]interface{}{"status": project.Status, "updated_at": project.UpdatedAt}).Error
+	s.audit(r, models.AuditUpdate, models.AuditEntityProject, projectID, projectID, before, project)
 	s.sendResponse(w, map[string]interface{}{"success": true, "message": "Project started successfully"})
 }
 
 func (s *Server) getProjectLogs(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleViewer) {
+		return
+	}
 
 	var project models.Project
 	if err := s.Svc.DB.Preload("Tasks").First(&project, "id = ?", projectID).Error; err != nil {
@@ -299,43 +435,44 @@ func (s *Server) getProjectLogs(w http.ResponseWriter, r *http.Request) {
 	logs := []string{}
 
 	// Add initial project startup logs
-	logs = append(logs, fmt.Sprintf("正在启动项目: %s", project.Name))
-	logs = append(logs, "开始初始化项目环境...")
+	lang := project.Locale
+	logs = append(logs, services.T(lang, "log.project_starting", project.Name))
+	logs = append(logs, services.T(lang, "log.env_init"))
 
 	// Add task generation logs if tasks exist
 	if len(project.Tasks) > 0 {
-		logs = append(logs, fmt.Sprintf("已生成 %d 个任务", len(project.Tasks)))
+		logs = append(logs, services.T(lang, "log.tasks_generated", len(project.Tasks)))
 
 		// Add logs for each task based on their status
 		for _, task := range project.Tasks {
 			switch task.Status {
 			case "created":
-				logs = append(logs, fmt.Sprintf("任务 [%s]: 已创建，等待执行", task.Name))
+				logs = append(logs, services.T(lang, "log.task_created", task.Name))
 			case "running":
-				logs = append(logs, fmt.Sprintf("任务 [%s]: 正在执行中...", task.Name))
+				logs = append(logs, services.T(lang, "log.task_running", task.Name))
 			case "completed":
-				logs = append(logs, fmt.Sprintf("任务 [%s]: 执行完成 ✓", task.Name))
+				logs = append(logs, services.T(lang, "log.task_completed", task.Name))
 			case "failed":
-				logs = append(logs, fmt.Sprintf("任务 [%s]: 执行失败 ✗", task.Name))
+				logs = append(logs, services.T(lang, "log.task_failed", task.Name))
 			}
 		}
 	} else {
-		logs = append(logs, "正在分析项目需求...")
-		logs = append(logs, "正在生成项目任务...")
+		logs = append(logs, services.T(lang, "log.analyzing"))
+		logs = append(logs, services.T(lang, "log.generating"))
 	}
 
 	// Add status-based logs
 	switch project.Status {
 	case "created":
-		logs = append(logs, "项目已创建，准备创建任务...")
+		logs = append(logs, services.T(lang, "log.project_created"))
 	case "running":
-		logs = append(logs, "项目正在运行中...")
-		logs = append(logs, "项目启动成功...")
+		logs = append(logs, services.T(lang, "log.project_running"))
+		logs = append(logs, services.T(lang, "log.project_started"))
 	case "completed":
-		logs = append(logs, "所有任务已完成!")
-		logs = append(logs, "项目执行成功 🎉")
+		logs = append(logs, services.T(lang, "log.project_all_done"))
+		logs = append(logs, services.T(lang, "log.project_completed"))
 	case "failed":
-		logs = append(logs, "项目执行过程中出现错误")
+		logs = append(logs, services.T(lang, "log.project_failed"))
 	}
 
 	s.sendResponse(w, map[string]interface{}{
@@ -348,6 +485,9 @@ func (s *Server) getProjectLogs(w http.ResponseWriter, r *http.Request) {
 func (s *Server) getProjectFiles(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleViewer) {
+		return
+	}
 	files := []map[string]interface{}{
 		{"name": "main.py", "type": "python", "size": 1024},
 		{"name": "requirements.txt", "type": "text", "size": 256},
@@ -359,6 +499,9 @@ func (s *Server) getProjectFiles(w http.ResponseWriter, r *http.Request) {
 func (s *Server) downloadProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleViewer) {
+		return
+	}
 	var project models.Project
 	if err := s.Svc.DB.First(&project, "id = ?", projectID).Error; err != nil {
 		s.sendError(w, "Project not found", http.StatusNotFound)
@@ -377,6 +520,9 @@ func (s *Server) downloadProject(w http.ResponseWriter, r *http.Request) {
 func (s *Server) deleteProject(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleOwner) {
+		return
+	}
 
 	// Check if project exists
 	var project models.Project
@@ -390,24 +536,124 @@ func (s *Server) deleteProject(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete project and associated tasks in a transaction
+	var tasks []models.Task
 	if err := s.Svc.DB.Transaction(func(tx *gorm.DB) error {
-		// Due to CASCADE constraint, deleting the project will automatically delete associated tasks
+		// The tasks are loaded for the audit log, the CASCADE constraint deletes them with the project
+		if err := tx.Where("project_id = ?", projectID).Find(&tasks).Error; err != nil {
+			return err
+		}
 		if err := tx.Delete(&project).Error; err != nil {
 			return err
 		}
 		return nil
 	}); err != nil {
-		log.Printf("Failed to delete project %s: %v", projectID, err)
+		slog.ErrorContext(r.Context(), "Failed to delete project", "project_id", projectID, "error", err)
 		s.sendError(w, "Failed to delete project", http.StatusInternalServerError)
 		return
 	}
 
 	// Remove from in-memory map if it exists
 	delete(s.Svc.Projects, projectID)
+	for _, t := range tasks {
+		delete(s.Svc.Tasks, t.ID)
+		s.audit(r, models.AuditDelete, models.AuditEntityTask, t.ID, projectID, t, nil)
+	}
+	s.audit(r, models.AuditDelete, models.AuditEntityProject, projectID, projectID, project, nil)
 
-	log.Printf("Successfully deleted project %s and its associated tasks", projectID)
+	slog.InfoContext(r.Context(), "Deleted project and its tasks", "project_id", projectID)
 	s.sendResponse(w, map[string]interface{}{
 		"success": true,
 		"message": "Project and associated tasks deleted successfully",
 	})
 }
+
+// regenerateProjectTasks produces a new task proposal and returns its diff against the current tasks.
+// Nothing is changed until the preview is applied.
+func (s *Server) regenerateProjectTasks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleManager) {
+		return
+	}
+	var project models.Project
+	if err := s.Svc.DB.Preload("Tasks").First(&project, "id = ?", projectID).Error; err != nil {
+		s.sendError(w, "Project not found", http.StatusNotFound)
+		return
+	}
+	preview, err := s.Svc.WithContext(r.Context()).PreviewTaskRegeneration(&project)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to regenerate tasks for project", "project_id", projectID, "error", err)
+		s.sendError(w, "Failed to regenerate tasks", http.StatusBadGateway)
+		return
+	}
+	s.sendResponse(w, preview)
+}
+
+func (s *Server) getTaskPlanPreview(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !s.authorizeProject(w, r, vars["id"], models.OrgRoleViewer) {
+		return
+	}
+	var preview models.TaskPlanPreview
+	if err := s.Svc.DB.First(&preview, "id = ? AND project_id = ?", vars["previewId"], vars["id"]).Error; err != nil {
+		s.sendError(w, "Preview not found", http.StatusNotFound)
+		return
+	}
+	s.sendResponse(w, preview)
+}
+
+func (s *Server) applyTaskPlan(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !s.authorizeProject(w, r, vars["id"], models.OrgRoleManager) {
+		return
+	}
+	var req models.ApplyTaskPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	result, err := s.Svc.ApplyTaskPlan(vars["id"], vars["previewId"], req.Accept, req.AcceptAll)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			s.sendError(w, "Preview not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrPreviewNotPending), errors.Is(err, services.ErrTaskPlanConflict):
+			s.sendError(w, err.Error(), http.StatusConflict)
+		default:
+			slog.ErrorContext(r.Context(), "Failed to apply task plan", "preview_id", vars["previewId"], "error", err)
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	for _, e := range result.Applied {
+		switch e.Action {
+		case "added":
+			s.audit(r, models.AuditCreate, models.AuditEntityTask, e.After.ID, vars["id"], nil, e.After)
+		case "removed":
+			delete(s.Svc.Tasks, e.Before.ID)
+			s.audit(r, models.AuditDelete, models.AuditEntityTask, e.Before.ID, vars["id"], e.Before, nil)
+		case "changed":
+			s.audit(r, models.AuditUpdate, models.AuditEntityTask, e.After.ID, vars["id"], e.Before, e.After)
+		}
+	}
+	s.sendResponse(w, result)
+}
+
+func (s *Server) discardTaskPlan(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	if !s.authorizeProject(w, r, vars["id"], models.OrgRoleManager) {
+		return
+	}
+	res := s.Svc.DB.Model(&models.TaskPlanPreview{}).
+		Where("id = ? AND project_id = ? AND status = ?", vars["previewId"], vars["id"], "pending").
+		Updates(map[string]interface{}{"status": "discarded", "updated_at": time.Now()})
+	if res.Error != nil {
+		s.sendError(w, "Failed to discard preview", http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		s.sendError(w, "Pending preview not found", http.StatusNotFound)
+		return
+	}
+	s.sendResponse(w, map[string]string{"message": "Preview discarded"})
+}
@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+	"neuro-dev/models"
+	"neuro-dev/services"
+)
+
+// Prompt template handlers. Versions are immutable: edits are posted as new versions and
+// rolling back means activating an older one.
+
+// listPromptTemplates returns prompt versions. Query params: kind, key, locale, active=true for active versions only.
+func (s *Server) listPromptTemplates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	templates, err := s.Svc.ListPromptTemplates(q.Get("kind"), q.Get("key"), q.Get("locale"), q.Get("active") == "true")
+	if err != nil {
+		s.sendError(w, "Failed to load prompt templates", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, templates)
+}
+
+func (s *Server) getPromptTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.sendError(w, "Invalid prompt template ID", http.StatusBadRequest)
+		return
+	}
+	var tmpl models.PromptTemplate
+	if err := s.Svc.DB.First(&tmpl, id).Error; err != nil {
+		s.sendError(w, "Prompt template not found", http.StatusNotFound)
+		return
+	}
+	s.sendResponse(w, tmpl)
+}
+
+func (s *Server) createPromptTemplate(w http.ResponseWriter, r *http.Request) {
+	var req models.CreatePromptTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	tmpl, err := s.Svc.CreatePromptVersion(req)
+	if err != nil {
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.sendResponse(w, tmpl)
+}
+
+func (s *Server) activatePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.sendError(w, "Invalid prompt template ID", http.StatusBadRequest)
+		return
+	}
+	tmpl, err := s.Svc.ActivatePromptVersion(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.sendError(w, "Prompt template not found", http.StatusNotFound)
+			return
+		}
+		s.sendError(w, "Failed to activate prompt template", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, tmpl)
+}
+
+func (s *Server) deletePromptTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		s.sendError(w, "Invalid prompt template ID", http.StatusBadRequest)
+		return
+	}
+	if err := s.Svc.DeletePromptVersion(uint(id)); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			s.sendError(w, "Prompt template not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrActivePromptDelete):
+			s.sendError(w, err.Error(), http.StatusConflict)
+		default:
+			s.sendError(w, "Failed to delete prompt template", http.StatusInternalServerError)
+		}
+		return
+	}
+	s.sendResponse(w, map[string]string{"message": "Prompt template deleted successfully"})
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"neuro-dev/models"
+)
+
+// Report-related handlers
+
+// getVarianceReport returns budget versus actual per project, task type and period.
+// Query params: project_id, from, to (YYYY-MM-DD, to is inclusive), interval (month|quarter|year|all),
+// include_bills (default true), format=csv for a CSV download.
+func (s *Server) getVarianceReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := models.VarianceQuery{
+		ProjectID:    q.Get("project_id"),
+		Projects:     requestScope(r).Projects,
+		Interval:     q.Get("interval"),
+		IncludeBills: q.Get("include_bills") != "false",
+	}
+	if v := q.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			s.sendError(w, "Invalid from date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		query.From = &from
+	}
+	if v := q.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			s.sendError(w, "Invalid to date, expected YYYY-MM-DD", http.StatusBadRequest)
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+		query.To = &to
+	}
+
+	report, err := s.Svc.BudgetVarianceReport(query)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to build variance report", "error", err)
+		s.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if q.Get("format") != "csv" {
+		s.sendResponse(w, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=variance-%s.csv", report.GeneratedAt.Format("20060102-150405")))
+	// BOM so that Excel opens Chinese project names correctly
+	_, _ = w.Write([]byte("\xef\xbb\xbf"))
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"project_id", "project_name", "task_type", "period", "budget", "actual", "variance", "variance_percent", "currency"})
+	for _, row := range report.Rows {
+		pct := ""
+		if row.VariancePercent != nil {
+			pct = strconv.FormatFloat(*row.VariancePercent, 'f', 2, 64)
+		}
+		_ = cw.Write([]string{
+			row.ProjectID,
+			row.ProjectName,
+			row.TaskType,
+			row.Period,
+			strconv.FormatFloat(row.Budget, 'f', 2, 64),
+			strconv.FormatFloat(row.Actual, 'f', 2, 64),
+			strconv.FormatFloat(row.Variance, 'f', 2, 64),
+			pct,
+			row.Currency,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to write variance CSV", "error", err)
+	}
+}
+
+// getEstimationAccuracy compares raw and calibrated estimates with recorded actuals.
+// Query params: project_id limits the rows to one project; factors always use the full history.
+// Rows only cover the caller's organizations.
+func (s *Server) getEstimationAccuracy(w http.ResponseWriter, r *http.Request) {
+	projectID := r.URL.Query().Get("project_id")
+	if projectID != "" && !s.authorizeProject(w, r, projectID, models.OrgRoleViewer) {
+		return
+	}
+	report, err := s.Svc.EstimationAccuracy(projectID, requestScope(r).Projects)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to build estimation accuracy report", "error", err)
+		s.sendError(w, "Failed to build estimation accuracy report", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, report)
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"neuro-dev/logging"
+	"neuro-dev/metrics"
+)
+
+const requestIDKey contextKey = "request_id"
+
+// maxRequestIDLength bounds client supplied request IDs
+const maxRequestIDLength = 64
+
+// assignRequestID tags every request with an ID, taken from X-Request-ID when the client sends one,
+// and echoes it in the response so log lines and audit entries can be correlated.
+func (s *Server) assignRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > maxRequestIDLength {
+			id = uuid.NewString()
+		}
+		w.Header().Set("X-Request-ID", id)
+		ctx := logging.With(context.WithValue(r.Context(), requestIDKey, id), "request_id", id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestID returns the ID assigned by assignRequestID
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// logRequests writes one log line per request with its status and duration and counts it in the HTTP metrics
+func (s *Server) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		elapsed := time.Since(start)
+		slog.InfoContext(r.Context(), "Request", "method", r.Method, "path", r.URL.Path, "status", rec.status,
+			"duration", elapsed)
+
+		route := r.URL.Path
+		if tmpl, err := mux.CurrentRoute(r).GetPathTemplate(); err == nil {
+			route = tmpl
+		}
+		metrics.HTTPRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		// A WebSocket request lasts as long as the connection
+		if rec.status != http.StatusSwitchingProtocols {
+			metrics.HTTPDuration.WithLabelValues(r.Method, route).Observe(elapsed.Seconds())
+		}
+	})
+}
+
+// statusRecorder remembers the status code written by a handler. It passes hijacking through for WebSockets.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -1,15 +1,22 @@
 package controllers
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"neuro-dev/config"
 	"neuro-dev/db"
+	"neuro-dev/metrics"
 	"neuro-dev/models"
 	"neuro-dev/services"
 )
@@ -18,21 +25,33 @@ type Server struct {
 	Router   *mux.Router
 	Upgrader websocket.Upgrader
 	Svc      *services.Service
+	Config   *config.Settings
+	sockets  sync.WaitGroup // open WebSocket connections, which http.Server.Shutdown does not wait for
 }
 
-func NewServer() *Server {
-	// Load configuration
-	cfg, err := config.Load()
+// NewServer connects to the database and prepares the routes for the loaded settings
+func NewServer(cfg *config.Settings) *Server {
+	tokens, err := services.NewTokenCipher(cfg.Security.TokenKey, cfg.Security.PreviousTokenKeys)
 	if err != nil {
 		panic(err)
 	}
-	// Initialize DB (Postgres via GORM)
+
+	// Initialize DB (Postgres, MySQL or SQLite via GORM)
 	dbConn, err := db.Init(cfg)
 	if err != nil {
 		panic(err)
 	}
-	// Auto-migrate models
-	if err := dbConn.AutoMigrate(&models.Project{}, &models.Task{}, &models.Model{}); err != nil {
+	// An in-memory database starts empty in every process, so `neuro-dev migrate` cannot prepare it
+	if db.IsSQLiteMemory(cfg) {
+		if _, err := db.MigrateUp(dbConn, services.MigrationData{ConfigDir: filepath.Dir(config.Path()), Tokens: tokens}); err != nil {
+			panic(err)
+		}
+	}
+	// The schema is managed by `neuro-dev migrate`; refuse to start on a database that is behind or ahead
+	if err := db.CheckSchema(dbConn); err != nil {
+		panic(err)
+	}
+	if err := metrics.RegisterDB(dbConn); err != nil {
 		panic(err)
 	}
 
@@ -41,15 +60,74 @@ func NewServer() *Server {
 		Upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool { return true },
 		},
-		Svc: services.NewService(dbConn),
+		Svc:    services.NewService(dbConn, services.NewCassetteClient(services.NewLangchainClient(cfg.LLM), cfg.LLM.CassetteDir)),
+		Config: cfg,
+	}
+	s.Svc.Tokens = tokens
+	s.Svc.JWT = cfg.JWT
+	if s.Svc.JWT.Secret == "" {
+		// Validation only lets dev mode run without a secret
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+		s.Svc.JWT.Secret = base64.RawStdEncoding.EncodeToString(secret)
+		slog.Warn("jwt.secret is not set, using a random secret; sessions end when the server restarts")
+	}
+	if cfg.Application.EnableDP {
+		rules, err := services.DataScopeRules(cfg.Application.DataScopes)
+		if err != nil {
+			panic(err)
+		}
+		s.Svc.DataScopes = rules
+		slog.Info("Data permissions enabled", "rules", rules)
+	}
+	// No execution survives a restart; tasks stopped mid-run can be started again and resume from their checkpoint.
+	// Tasks checkpointed within the shutdown timeout may still be finishing in a process that is shutting down.
+	if n, err := s.Svc.ResetInterruptedTasks(cfg.Application.ShutdownDuration()); err != nil {
+		panic(err)
+	} else if n > 0 {
+		slog.Info("Reset interrupted tasks to pending", "count", n)
+	}
+	if err := s.Svc.EnsureAdmin(cfg.Admin.Username, cfg.Admin.Password); err != nil {
+		panic(err)
+	}
+	// Tokens stored before a key was set are encrypted by `neuro-dev rotate-token-key`
+	if !tokens.Enabled() {
+		slog.Warn("security.token_key is not set, model tokens are stored in plain text")
 	}
 	s.setupRoutes()
 	return s
 }
 
 func (s *Server) setupRoutes() {
+	// Every route except login, refresh and health requires an access token
+	s.Router.Use(s.assignRequestID, s.logRequests, s.authenticate)
 	api := s.Router.PathPrefix("/api").Subrouter()
 
+	// Auth endpoints
+	api.HandleFunc("/auth/login", s.login).Methods("POST")
+	api.HandleFunc("/auth/refresh", s.refreshToken).Methods("POST")
+	api.HandleFunc("/auth/me", s.getCurrentUser).Methods("GET")
+	api.HandleFunc("/auth/password", s.sessionOnly(s.changePassword)).Methods("POST")
+	api.HandleFunc("/users", s.adminOnly(s.listUsers)).Methods("GET")
+	api.HandleFunc("/users", s.adminOnly(s.createUser)).Methods("POST")
+	api.HandleFunc("/users/{id}/department", s.adminOnly(s.setUserDepartment)).Methods("PUT")
+
+	// API key endpoints
+	api.HandleFunc("/api-keys", s.sessionOnly(s.listAPIKeys)).Methods("GET")
+	api.HandleFunc("/api-keys", s.sessionOnly(s.createAPIKey)).Methods("POST")
+	api.HandleFunc("/api-keys/{id}", s.sessionOnly(s.revokeAPIKey)).Methods("DELETE")
+
+	// Organization endpoints
+	api.HandleFunc("/organizations", s.listOrganizations).Methods("GET")
+	api.HandleFunc("/organizations", s.createOrganization).Methods("POST")
+	api.HandleFunc("/organizations/{id}", s.deleteOrganization).Methods("DELETE")
+	api.HandleFunc("/organizations/{id}/members", s.listOrganizationMembers).Methods("GET")
+	api.HandleFunc("/organizations/{id}/members", s.setOrganizationMember).Methods("POST")
+	api.HandleFunc("/organizations/{id}/members/{userId}", s.setOrganizationMember).Methods("PUT")
+	api.HandleFunc("/organizations/{id}/members/{userId}", s.removeOrganizationMember).Methods("DELETE")
+
 	// Project endpoints
 	api.HandleFunc("/projects", s.listProjects).Methods("GET")
 	api.HandleFunc("/projects", s.createProject).Methods("POST")
@@ -61,6 +139,10 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/projects/{id}/logs", s.getProjectLogs).Methods("GET")
 	api.HandleFunc("/projects/{id}/files", s.getProjectFiles).Methods("GET")
 	api.HandleFunc("/projects/{id}/download", s.downloadProject).Methods("POST")
+	api.HandleFunc("/projects/{id}/regenerate", s.regenerateProjectTasks).Methods("POST")
+	api.HandleFunc("/projects/{id}/regenerate/{previewId}", s.getTaskPlanPreview).Methods("GET")
+	api.HandleFunc("/projects/{id}/regenerate/{previewId}", s.discardTaskPlan).Methods("DELETE")
+	api.HandleFunc("/projects/{id}/regenerate/{previewId}/apply", s.applyTaskPlan).Methods("POST")
 
 	// Task endpoints
 	api.HandleFunc("/projects/{projectId}/tasks", s.createTask).Methods("POST")
@@ -70,6 +152,45 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/tasks/{id}", s.deleteTask).Methods("DELETE")
 	api.HandleFunc("/tasks/{id}/start", s.startTask).Methods("POST")
 	api.HandleFunc("/tasks/{id}/status", s.getTaskStatus).Methods("GET")
+	api.HandleFunc("/tasks/{id}/subtasks", s.getSubtasks).Methods("GET")
+	api.HandleFunc("/tasks/{id}/transcripts", s.getTaskTranscripts).Methods("GET")
+	api.HandleFunc("/tasks/{id}/decompose", s.decomposeTask).Methods("POST")
+	api.HandleFunc("/tasks/{id}/complete", s.completeTask).Methods("POST")
+
+	// Bill endpoints
+	api.HandleFunc("/bills", s.listBills).Methods("GET")
+	api.HandleFunc("/bills/import", s.importBills).Methods("POST")
+	api.HandleFunc("/bills/remap", s.adminOnly(s.remapBills)).Methods("POST")
+	api.HandleFunc("/bills/rules", s.listBillRules).Methods("GET")
+	api.HandleFunc("/bills/rules", s.createBillRule).Methods("POST")
+	api.HandleFunc("/bills/rules/{id}", s.deleteBillRule).Methods("DELETE")
+
+	// Exchange rate endpoints
+	api.HandleFunc("/exchange-rates", s.listExchangeRates).Methods("GET")
+	api.HandleFunc("/exchange-rates", s.adminOnly(s.createExchangeRate)).Methods("POST")
+	api.HandleFunc("/exchange-rates/import", s.adminOnly(s.importExchangeRates)).Methods("POST")
+	api.HandleFunc("/exchange-rates/{id}", s.adminOnly(s.deleteExchangeRate)).Methods("DELETE")
+
+	// Report endpoints
+	api.HandleFunc("/reports/variance", s.getVarianceReport).Methods("GET")
+	api.HandleFunc("/reports/estimation-accuracy", s.getEstimationAccuracy).Methods("GET")
+
+	// Audit log endpoints
+	api.HandleFunc("/audit-logs", s.listAuditLogs).Methods("GET")
+
+	// Prompt template endpoints
+	api.HandleFunc("/prompts", s.listPromptTemplates).Methods("GET")
+	api.HandleFunc("/prompts", s.adminOnly(s.createPromptTemplate)).Methods("POST")
+	api.HandleFunc("/prompts/{id}", s.getPromptTemplate).Methods("GET")
+	api.HandleFunc("/prompts/{id}", s.adminOnly(s.deletePromptTemplate)).Methods("DELETE")
+	api.HandleFunc("/prompts/{id}/activate", s.adminOnly(s.activatePromptTemplate)).Methods("POST")
+
+	// Admin endpoints
+	api.HandleFunc("/admin/cassettes", s.adminOnly(s.listCassettes)).Methods("GET")
+	api.HandleFunc("/admin/cassettes/{name}", s.adminOnly(s.getCassette)).Methods("GET")
+	api.HandleFunc("/admin/projects/{id}/recording", s.adminOnly(s.startRecording)).Methods("POST")
+	api.HandleFunc("/admin/projects/{id}/recording", s.adminOnly(s.stopRecording)).Methods("DELETE")
+	api.HandleFunc("/admin/projects/{id}/replay", s.adminOnly(s.replayProject)).Methods("POST")
 
 	// Configuration endpoints
 	api.HandleFunc("/config/companies", s.getCompanies).Methods("GET")
@@ -79,6 +200,7 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/models", s.createModel).Methods("POST")
 	api.HandleFunc("/models/{id}", s.updateModel).Methods("PUT")
 	api.HandleFunc("/models/{id}", s.deleteModel).Methods("DELETE")
+	api.HandleFunc("/models/{id}/check", s.checkModel).Methods("POST")
 	api.HandleFunc("/models/{name}/token", s.updateModelToken).Methods("PUT")
 
 	// WebSocket
@@ -88,6 +210,29 @@ func (s *Server) setupRoutes() {
 	api.HandleFunc("/health", s.healthCheck).Methods("GET")
 }
 
+// Shutdown stops running tasks after their current phase, closes WebSocket connections and then the
+// database. Call it after http.Server.Shutdown so no request is still using the database.
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.Svc.Shutdown(ctx); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		s.sockets.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("wait for websocket connections failed: %w", ctx.Err())
+	}
+	sqlDB, err := s.Svc.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 // Helpers
 func (s *Server) sendResponse(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -104,28 +249,46 @@ func (s *Server) sendError(w http.ResponseWriter, message string, statusCode int
 
 // Other endpoints
 
+// websocketWriteWait bounds each WebSocket write, including the close frame sent on shutdown
+const websocketWriteWait = 10 * time.Second
+
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["id"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleViewer) {
+		return
+	}
 	conn, err := s.Upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		slog.WarnContext(r.Context(), "WebSocket upgrade failed", "project_id", projectID, "error", err)
 		return
 	}
+	s.sockets.Add(1)
+	defer s.sockets.Done()
+	metrics.WebSockets.Inc()
+	defer metrics.WebSockets.Dec()
 	defer conn.Close()
 	for {
 		var project models.Project
 		if err := s.Svc.DB.Preload("Tasks").First(&project, "id = ?", projectID).Error; err != nil {
 			break
 		}
+		// The connection inherits the server's write timeout, so every write gets its own deadline
+		_ = conn.SetWriteDeadline(time.Now().Add(websocketWriteWait))
 		if err := conn.WriteJSON(project); err != nil {
-			log.Printf("WebSocket write error: %v", err)
+			slog.WarnContext(r.Context(), "WebSocket write failed", "project_id", projectID, "error", err)
 			break
 		}
-		time.Sleep(1 * time.Second)
 		if project.Status == "completed" || project.Status == "failed" {
 			break
 		}
+		select {
+		case <-time.After(1 * time.Second):
+		case <-s.Svc.Stopping():
+			msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+			_ = conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(websocketWriteWait))
+			return
+		}
 	}
 }
 
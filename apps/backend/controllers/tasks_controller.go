@@ -2,20 +2,27 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gorilla/mux"
+	"gorm.io/gorm"
 	"neuro-dev/models"
+	"neuro-dev/services"
 )
 
 // Task-related handlers
 func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["projectId"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleManager) {
+		return
+	}
 	// Ensure project exists in DB
-	var tmp int64
-	if err := s.Svc.DB.Model(&models.Project{}).Where("id = ?", projectID).Count(&tmp).Error; err != nil || tmp == 0 {
+	var project models.Project
+	if err := s.Svc.DB.Select("id", "reporting_currency").First(&project, "id = ?", projectID).Error; err != nil {
 		s.sendError(w, "Project not found", http.StatusNotFound)
 		return
 	}
@@ -29,8 +36,29 @@ func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Costs default to the project's reporting currency
+	currency := project.ReportingCurrency
+	if req.Currency != "" {
+		c, err := services.NormalizeCurrency(req.Currency)
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		currency = c
+	}
+	if currency == "" {
+		currency = models.DefaultCurrency
+	}
+
 	taskID := s.Svc.NextTaskID()
 
+	if req.ParentID != "" {
+		if err := s.Svc.ValidateTaskParent(s.Svc.DB, projectID, "", req.ParentID); err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	// 设置默认费用类型为预算
 	expenseType := req.ExpenseType
 	if expenseType == "" {
@@ -40,6 +68,7 @@ func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
 	task := models.Task{
 		ID:            taskID,
 		ProjectID:     projectID,
+		ParentID:      req.ParentID,
 		Name:          req.Name,
 		Description:   req.Description,
 		Type:          req.Type,
@@ -48,36 +77,52 @@ func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
 		Requirements:  req.Requirements,
 		EstimatedDays: req.EstimatedDays,
 		EstimatedCost: req.EstimatedCost,
+		Currency:      currency,
 		ExpenseType:   expenseType,
 		CreatedAt:     time.Now(),
 		UpdatedAt:     time.Now(),
 		Progress:      0,
 		Results:       models.TaskResults{},
 	}
-	if err := s.Svc.DB.Create(&task).Error; err != nil {
+	if err := s.Svc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		return s.Svc.RollupTaskAncestors(tx, task.ParentID)
+	}); err != nil {
 		s.sendError(w, "Failed to create task", http.StatusInternalServerError)
 		return
 	}
 	// optional in-memory
 	s.Svc.Tasks[taskID] = &task
 	_ = s.Svc.DB.Model(&models.Project{}).Where("id = ?", projectID).Update("updated_at", time.Now()).Error
+	s.audit(r, models.AuditCreate, models.AuditEntityTask, taskID, projectID, nil, task)
 	s.sendResponse(w, task)
 }
 
 func (s *Server) getProjectTasks(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	projectID := vars["projectId"]
+	if !s.authorizeProject(w, r, projectID, models.OrgRoleViewer) {
+		return
+	}
 	var tasks []models.Task
-	if err := s.Svc.DB.Where("project_id = ?", projectID).Find(&tasks).Error; err != nil {
+	if err := s.Svc.DB.Scopes(requestScope(r).Tasks).Where("project_id = ?", projectID).Find(&tasks).Error; err != nil {
 		s.sendError(w, "Failed to load tasks", http.StatusInternalServerError)
 		return
 	}
+	if r.URL.Query().Get("tree") == "true" {
+		tasks = services.BuildTaskTree(tasks)
+	}
 	s.sendResponse(w, tasks)
 }
 
 func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleViewer) {
+		return
+	}
 	var task models.Task
 	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
 		s.sendError(w, "Task not found", http.StatusNotFound)
@@ -89,6 +134,9 @@ func (s *Server) getTask(w http.ResponseWriter, r *http.Request) {
 func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleManager) {
+		return
+	}
 	var task models.Task
 	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
 		s.sendError(w, "Task not found", http.StatusNotFound)
@@ -99,6 +147,7 @@ func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 		s.sendError(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	before := task
 	if req.Name != "" {
 		task.Name = req.Name
 	}
@@ -123,27 +172,91 @@ func (s *Server) updateTask(w http.ResponseWriter, r *http.Request) {
 	if req.ExpenseType != "" {
 		task.ExpenseType = req.ExpenseType
 	}
+	if req.Currency != "" {
+		currency, err := services.NormalizeCurrency(req.Currency)
+		if err != nil {
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		task.Currency = currency
+	}
+	previousParent := task.ParentID
+	if req.ClearParent {
+		if req.ParentID != "" {
+			s.sendError(w, "parent_id and clear_parent cannot be combined", http.StatusBadRequest)
+			return
+		}
+		task.ParentID = ""
+	} else if req.ParentID != "" && req.ParentID != task.ParentID {
+		if err := s.Svc.ValidateTaskParent(s.Svc.DB, task.ProjectID, task.ID, req.ParentID); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, services.ErrTaskCycle) {
+				status = http.StatusConflict
+			}
+			s.sendError(w, err.Error(), status)
+			return
+		}
+		task.ParentID = req.ParentID
+	}
 	task.UpdatedAt = time.Now()
-	if err := s.Svc.DB.Save(&task).Error; err != nil {
+	if err := s.Svc.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+		// Estimates of a task with subtasks are derived from them, so roll up again after manual edits
+		if err := s.Svc.RollupTaskAncestors(tx, task.ID); err != nil {
+			return err
+		}
+		if previousParent != task.ParentID {
+			return s.Svc.RollupTaskAncestors(tx, previousParent)
+		}
+		return nil
+	}); err != nil {
 		s.sendError(w, "Failed to update task", http.StatusInternalServerError)
 		return
 	}
+	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
+		s.sendError(w, "Failed to fetch updated task", http.StatusInternalServerError)
+		return
+	}
+	s.audit(r, models.AuditUpdate, models.AuditEntityTask, taskID, task.ProjectID, before, task)
 	s.sendResponse(w, task)
 }
 
 func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleManager) {
+		return
+	}
 	var task models.Task
 	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
 		s.sendError(w, "Task not found", http.StatusNotFound)
 		return
 	}
-	if err := s.Svc.DB.Delete(&models.Task{}, "id = ?", taskID).Error; err != nil {
+	// Subtasks are deleted together with their parent
+	var removed []models.Task
+	if err := s.Svc.DB.Transaction(func(tx *gorm.DB) error {
+		ids, err := s.Svc.DescendantTaskIDs(tx, taskID)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, taskID)
+		if err := tx.Where("id IN ?", ids).Find(&removed).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Task{}, "id IN ?", ids).Error; err != nil {
+			return err
+		}
+		return s.Svc.RollupTaskAncestors(tx, task.ParentID)
+	}); err != nil {
 		s.sendError(w, "Failed to delete task", http.StatusInternalServerError)
 		return
 	}
-	delete(s.Svc.Tasks, taskID)
+	for _, t := range removed {
+		delete(s.Svc.Tasks, t.ID)
+		s.audit(r, models.AuditDelete, models.AuditEntityTask, t.ID, t.ProjectID, t, nil)
+	}
 	_ = s.Svc.DB.Model(&models.Project{}).Where("id = ?", task.ProjectID).Update("updated_at", time.Now()).Error
 	s.sendResponse(w, map[string]string{"message": "Task deleted successfully"})
 }
@@ -151,6 +264,9 @@ func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request) {
 func (s *Server) startTask(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleManager) {
+		return
+	}
 	var task models.Task
 	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
 		s.sendError(w, "Task not found", http.StatusNotFound)
@@ -165,16 +281,23 @@ func (s *Server) startTask(w http.ResponseWriter, r *http.Request) {
 		s.sendError(w, "Project not found", http.StatusNotFound)
 		return
 	}
+	// The execution flips the status in the background, so the audited states are copies taken beforehand
+	before, started := task, task
+	started.Status = "in_progress"
 	// optional in-memory
 	s.Svc.Tasks[taskID] = &task
 	s.Svc.Projects[project.ID] = &project
-	go s.Svc.ExecuteTask(&task, &project)
+	s.Svc.WithContext(r.Context()).RunTask(&task, &project)
+	s.audit(r, models.AuditUpdate, models.AuditEntityTask, taskID, before.ProjectID, before, started)
 	s.sendResponse(w, map[string]string{"message": "Task started successfully"})
 }
 
 func (s *Server) getTaskStatus(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	taskID := vars["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleViewer) {
+		return
+	}
 	var task models.Task
 	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
 		s.sendError(w, "Task not found", http.StatusNotFound)
@@ -188,3 +311,132 @@ func (s *Server) getTaskStatus(w http.ResponseWriter, r *http.Request) {
 		"results":       task.Results,
 	})
 }
+
+// getTaskTranscripts returns the prompts of the task's executed phases with their template versions
+func (s *Server) getTaskTranscripts(w http.ResponseWriter, r *http.Request) {
+	taskID := mux.Vars(r)["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleViewer) {
+		return
+	}
+	transcripts, err := s.Svc.TaskTranscripts(taskID)
+	if err != nil {
+		s.sendError(w, "Failed to load task transcripts", http.StatusInternalServerError)
+		return
+	}
+	s.sendResponse(w, transcripts)
+}
+
+func (s *Server) getSubtasks(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID := vars["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleViewer) {
+		return
+	}
+	var task models.Task
+	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
+		s.sendError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	ids, err := s.Svc.DescendantTaskIDs(s.Svc.DB, taskID)
+	if err != nil {
+		s.sendError(w, "Failed to load subtasks", http.StatusInternalServerError)
+		return
+	}
+	subtasks := []models.Task{}
+	if len(ids) > 0 {
+		if err := s.Svc.DB.Where("id IN ?", ids).Find(&subtasks).Error; err != nil {
+			s.sendError(w, "Failed to load subtasks", http.StatusInternalServerError)
+			return
+		}
+	}
+	task.Children = services.BuildTaskTree(subtasks)
+	s.sendResponse(w, task)
+}
+
+// decomposeTask asks the project's model to split a task into subtasks and stores them below it
+func (s *Server) decomposeTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID := vars["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleManager) {
+		return
+	}
+	var task models.Task
+	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
+		s.sendError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	var children int64
+	if err := s.Svc.DB.Model(&models.Task{}).Where("parent_id = ?", taskID).Count(&children).Error; err != nil {
+		s.sendError(w, "Failed to load subtasks", http.StatusInternalServerError)
+		return
+	}
+	if children > 0 {
+		s.sendError(w, "Task already has subtasks", http.StatusConflict)
+		return
+	}
+	var project models.Project
+	if err := s.Svc.DB.Select("id", "model", "locale").First(&project, "id = ?", task.ProjectID).Error; err != nil {
+		s.sendError(w, "Project not found", http.StatusNotFound)
+		return
+	}
+
+	before := task
+	svc := s.Svc.WithContext(r.Context())
+	subtasks, err := svc.DecomposeTask(&task, &project)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to decompose task", "task_id", taskID, "error", err)
+		s.sendError(w, "Failed to decompose task: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	svc.CalibrateTasks(subtasks, project.Model)
+	if err := s.Svc.CreateSubtasks(&task, subtasks); err != nil {
+		s.sendError(w, "Failed to save subtasks", http.StatusInternalServerError)
+		return
+	}
+	if err := s.Svc.DB.First(&task, "id = ?", taskID).Error; err != nil {
+		s.sendError(w, "Failed to fetch updated task", http.StatusInternalServerError)
+		return
+	}
+	for _, sub := range subtasks {
+		s.audit(r, models.AuditCreate, models.AuditEntityTask, sub.ID, sub.ProjectID, nil, sub)
+	}
+	s.audit(r, models.AuditUpdate, models.AuditEntityTask, taskID, task.ProjectID, before, task)
+	task.Children = services.BuildTaskTree(subtasks)
+	_ = s.Svc.DB.Model(&models.Project{}).Where("id = ?", task.ProjectID).Update("updated_at", time.Now()).Error
+	s.sendResponse(w, task)
+}
+
+// completeTask marks a task completed and records its actual effort for estimate calibration
+func (s *Server) completeTask(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	taskID := vars["id"]
+	if !s.authorizeTask(w, r, taskID, models.OrgRoleManager) {
+		return
+	}
+	var req models.CompleteTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.sendError(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	var before models.Task
+	if err := s.Svc.DB.First(&before, "id = ?", taskID).Error; err != nil {
+		s.sendError(w, "Task not found", http.StatusNotFound)
+		return
+	}
+	task, err := s.Svc.CompleteTask(taskID, req.ActualDays, req.ActualCost)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			s.sendError(w, "Task not found", http.StatusNotFound)
+		case errors.Is(err, services.ErrTaskHasSubtasks):
+			s.sendError(w, err.Error(), http.StatusConflict)
+		default:
+			s.sendError(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	delete(s.Svc.Tasks, taskID)
+	_ = s.Svc.DB.Model(&models.Project{}).Where("id = ?", task.ProjectID).Update("updated_at", time.Now()).Error
+	s.audit(r, models.AuditUpdate, models.AuditEntityTask, taskID, task.ProjectID, before, *task)
+	s.sendResponse(w, task)
+}
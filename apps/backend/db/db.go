@@ -3,88 +3,188 @@ package db
 import (
 	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
 	"neuro-dev/config"
+	"neuro-dev/logging"
 )
 
 var DB *gorm.DB
 
+// Supported values of settings.database.driver
+const (
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+	DriverSQLite   = "sqlite3"
+)
+
+// defaultMySQLParams are used when the mysql source has no query string
+const defaultMySQLParams = "charset=utf8mb4&parseTime=True&loc=Local&timeout=1000ms"
+
 // Init initializes the global DB handle using settings
 func Init(settings *config.Settings) (*gorm.DB, error) {
 	if settings == nil {
 		return nil, errors.New("nil settings")
 	}
-	d := strings.ToLower(strings.TrimSpace(settings.Database.Driver))
-	if d != "postgres" {
-		return nil, fmt.Errorf("unsupported driver: %s (only postgres is supported)", settings.Database.Driver)
-	}
-	dsn, err := buildPostgresDSN(settings)
+	dialector, err := openDialector(settings)
 	if err != nil {
 		return nil, err
 	}
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logging.Gorm(settings.Logger.EnableDB)})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect database: %w", err)
 	}
+	// Every connection to :memory: opens a separate empty database, so the pool is pinned to one
+	// connection that is never closed
+	if IsSQLiteMemory(settings) {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
 	DB = db
 	return db, nil
 }
 
+// IsSQLiteMemory reports whether the settings select an in-memory SQLite database, which lives only as
+// long as the process
+func IsSQLiteMemory(settings *config.Settings) bool {
+	switch strings.ToLower(strings.TrimSpace(settings.Database.Driver)) {
+	case DriverSQLite, "sqlite":
+		path, _, _ := strings.Cut(strings.TrimSpace(settings.Database.Source), "?")
+		return path == ":memory:"
+	}
+	return false
+}
+
+// openDialector picks the GORM dialector for the configured driver
+func openDialector(settings *config.Settings) (gorm.Dialector, error) {
+	switch d := strings.ToLower(strings.TrimSpace(settings.Database.Driver)); d {
+	case DriverPostgres:
+		dsn, err := buildPostgresDSN(settings)
+		if err != nil {
+			return nil, err
+		}
+		return postgres.Open(dsn), nil
+	case DriverMySQL:
+		dsn, err := buildMySQLDSN(settings)
+		if err != nil {
+			return nil, err
+		}
+		return mysql.Open(dsn), nil
+	case DriverSQLite, "sqlite":
+		dsn, err := buildSQLiteDSN(settings)
+		if err != nil {
+			return nil, err
+		}
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported driver: %s (expected postgres, mysql or sqlite3)", settings.Database.Driver)
+	}
+}
+
 // buildPostgresDSN builds a postgres DSN from settings
 // It supports source in the format: tcp(host:port)/dbname or host:port/dbname.
 func buildPostgresDSN(s *config.Settings) (string, error) {
-	user := s.Database.User
-	password := s.Database.Password
+	host, port, dbname, err := parseSource(s.Database.Source, "5432")
+	if err != nil {
+		return "", fmt.Errorf("invalid postgres source: %w", err)
+	}
+
+	// Compose DSN
+	// sslmode disabled by default; can be extended later
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, s.Database.User, s.Database.Password, dbname)
+	return dsn, nil
+}
+
+// buildMySQLDSN builds a go-sql-driver DSN from settings
+// It supports source in the format: tcp(host:port)/dbname?params or host:port/dbname?params.
+// Without params, utf8mb4 with parseTime is used so time columns scan into time.Time.
+func buildMySQLDSN(s *config.Settings) (string, error) {
+	host, port, dbname, err := parseSource(s.Database.Source, "3306")
+	if err != nil {
+		return "", fmt.Errorf("invalid mysql source: %w", err)
+	}
+	params := defaultMySQLParams
+	if i := strings.Index(dbname, "?"); i >= 0 {
+		params = dbname[i+1:]
+		dbname = dbname[:i]
+	}
+	if dbname == "" {
+		return "", fmt.Errorf("missing dbname in source: %s", s.Database.Source)
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?%s", s.Database.User, s.Database.Password, host, port, dbname, params)
+	return dsn, nil
+}
+
+// buildSQLiteDSN builds a sqlite DSN from settings
+// The source is a file path, created with its directory when missing, or :memory:.
+// Foreign keys are switched on so project deletes cascade to tasks as in the other databases.
+func buildSQLiteDSN(s *config.Settings) (string, error) {
 	source := strings.TrimSpace(s.Database.Source)
-	var host, port, dbname string
+	if source == "" {
+		return "", errors.New("missing sqlite database file in source")
+	}
+	path, params, _ := strings.Cut(source, "?")
+	if path != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", fmt.Errorf("create sqlite directory failed: %w", err)
+		}
+	}
+	pragmas := "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)"
+	if params != "" {
+		pragmas += "&" + params
+	}
+	return path + "?" + pragmas, nil
+}
+
+// parseSource splits a source of the form tcp(host:port)/dbname or host:port/dbname.
+// Everything after the first slash is returned as dbname.
+func parseSource(source, defaultPort string) (host, port, dbname string, err error) {
+	source = strings.TrimSpace(source)
+	var hostPort string
 
 	// Remove optional tcp(...) wrapper
 	if strings.HasPrefix(source, "tcp(") && strings.Contains(source, ")/") {
 		// tcp(host:port)/dbname
 		leftRight := strings.SplitN(source, ")/", 2)
-		inner := strings.TrimPrefix(leftRight[0], "tcp(")
-		hostPort := inner
+		hostPort = strings.TrimPrefix(leftRight[0], "tcp(")
 		dbname = leftRight[1]
-		// split host:port
-		if hp := strings.Split(hostPort, ":"); len(hp) == 2 {
-			host = hp[0]
-			port = hp[1]
-		} else {
-			host = hostPort
-			port = "5432"
-		}
 	} else if strings.Contains(source, "/") {
 		// host:port/dbname
 		parts := strings.SplitN(source, "/", 2)
-		hostPort := parts[0]
+		hostPort = parts[0]
 		dbname = parts[1]
-		if hp := strings.Split(hostPort, ":"); len(hp) == 2 {
-			host = hp[0]
-			port = hp[1]
-		} else {
-			host = hostPort
-			port = "5432"
-		}
 	} else {
-		return "", fmt.Errorf("invalid postgres source: %s", source)
+		return "", "", "", fmt.Errorf("unrecognised format %q, expected tcp(host:port)/dbname or host:port/dbname", source)
+	}
+	// split host:port
+	if hp := strings.Split(hostPort, ":"); len(hp) == 2 {
+		host = hp[0]
+		port = hp[1]
+	} else {
+		host = hostPort
 	}
 
 	if dbname == "" {
-		return "", fmt.Errorf("missing dbname in source: %s", source)
+		return "", "", "", fmt.Errorf("missing dbname in source: %s", source)
 	}
 	if host == "" {
 		host = "127.0.0.1"
 	}
 	if port == "" {
-		port = "5432"
+		port = defaultPort
 	}
-
-	// Compose DSN
-	// sslmode disabled by default; can be extended later
-	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", host, port, user, password, dbname)
-	return dsn, nil
+	return host, port, dbname, nil
 }
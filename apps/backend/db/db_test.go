@@ -0,0 +1,133 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"neuro-dev/config"
+	"neuro-dev/models"
+)
+
+// testData seeds one prompt and marks the tokens it encrypts
+type testData struct{}
+
+func (testData) PromptSeeds() ([]PromptSeed, error) {
+	return []PromptSeed{{Kind: "phase", Key: "Coding", Locale: "en", Content: "write {task}"}}, nil
+}
+
+func (testData) EncryptToken(token string) (string, bool, error) {
+	return "enc:" + token, true, nil
+}
+
+// TestSQLiteSmoke migrates an in-memory database, runs the main queries against the migrated schema and
+// reverts every migration again
+func TestSQLiteSmoke(t *testing.T) {
+	conn, err := Init(&config.Settings{Database: config.Database{Driver: DriverSQLite, Source: ":memory:"}})
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if _, err := MigrateUp(conn, nil); err == nil {
+		t.Fatal("data migrations ran without a data source")
+	}
+	if _, err := MigrateUp(conn, testData{}); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if err := CheckSchema(conn); err != nil {
+		t.Fatalf("CheckSchema after migrate up: %v", err)
+	}
+	var prompt models.PromptTemplate
+	if err := conn.First(&prompt, "kind = ? AND prompt_key = ? AND locale = ?", "phase", "Coding", "en").Error; err != nil {
+		t.Fatalf("load seeded prompt: %v", err)
+	}
+	if prompt.Version != 1 || !prompt.Active || prompt.Content != "write {task}" {
+		t.Errorf("seeded prompt = %+v", prompt)
+	}
+
+	user := models.User{Username: "alice", PasswordHash: "x", Department: "A"}
+	org := models.Organization{Name: "org1"}
+	if err := conn.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := conn.Create(&org).Error; err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	if err := conn.Create(&models.OrganizationMember{OrganizationID: org.ID, UserID: user.ID, Role: models.OrgRoleOwner}).Error; err != nil {
+		t.Fatalf("create member: %v", err)
+	}
+	project := models.Project{ID: "p1", Name: "demo", OrganizationID: org.ID, CreatedBy: user.ID, Department: "A"}
+	if err := conn.Create(&project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	now := time.Now()
+	tasks := []models.Task{
+		{ID: "t1", ProjectID: "p1", Name: "parent", Status: "pending"},
+		{ID: "t2", ProjectID: "p1", ParentID: "t1", Name: "child", Status: "in_progress", StartedAt: &now},
+	}
+	if err := conn.Create(&tasks).Error; err != nil {
+		t.Fatalf("create tasks: %v", err)
+	}
+	if err := conn.Create(&models.TaskTranscript{TaskID: "t2", ProjectID: "p1", Phase: "Coding"}).Error; err != nil {
+		t.Fatalf("create transcript: %v", err)
+	}
+	bill := models.Bill{ID: "b1", ProjectID: "p1", Vendor: "aws", Amount: 1.5, Currency: "USD", PeriodStart: now, PeriodEnd: now}
+	bill.Fingerprint = models.BillFingerprint(bill.DedupeKey(), 0)
+	if err := conn.Create(&bill).Error; err != nil {
+		t.Fatalf("create bill: %v", err)
+	}
+	if err := conn.Create(&models.Bill{ID: "b2", Fingerprint: bill.Fingerprint}).Error; err == nil {
+		t.Error("bill with a duplicate fingerprint was saved")
+	}
+
+	var loaded models.Project
+	if err := conn.Preload("Tasks").First(&loaded, "id = ?", "p1").Error; err != nil {
+		t.Fatalf("load project: %v", err)
+	}
+	if len(loaded.Tasks) != 2 || loaded.ReportingCurrency != "CNY" {
+		t.Errorf("loaded project = %+v", loaded)
+	}
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	if err := conn.Table("tasks").Select("status, COUNT(*) AS count").Group("status").Order("status").Scan(&counts).Error; err != nil {
+		t.Fatalf("count tasks: %v", err)
+	}
+	if len(counts) != 2 {
+		t.Errorf("task counts = %+v", counts)
+	}
+
+	// Deleting the project cascades to its tasks and their transcripts
+	if err := conn.Delete(&loaded).Error; err != nil {
+		t.Fatalf("delete project: %v", err)
+	}
+	for _, table := range []string{"tasks", "task_transcripts"} {
+		var n int64
+		if err := conn.Table(table).Count(&n).Error; err != nil {
+			t.Fatalf("count %s: %v", table, err)
+		}
+		if n != 0 {
+			t.Errorf("%d rows left in %s after the project was deleted", n, table)
+		}
+	}
+
+	if _, err := MigrateDown(conn, len(migrations)); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if err := CheckSchema(conn); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("CheckSchema after migrate down = %v, want ErrSchemaOutdated", err)
+	}
+	if conn.Migrator().HasTable("projects") {
+		t.Error("projects table left after reverting the baseline")
+	}
+	if err := conn.First(&models.Project{}).Error; err == nil || errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("query on a reverted schema = %v, want a missing table error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrSchemaOutdated is returned by CheckSchema when the database and the build disagree on migrations
+var ErrSchemaOutdated = errors.New("database schema is not up to date")
+
+// Migration is one versioned schema change. Released migrations are never edited;
+// change the schema by appending a new one to migrations.
+type Migration struct {
+	Version string // sortable, e.g. 0002
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // nil when the change cannot be reverted
+}
+
+// SchemaMigration is a row of schema_migrations, one per applied migration
+type SchemaMigration struct {
+	Version   string `gorm:"primaryKey;size:32"`
+	Name      string `gorm:"size:128"`
+	AppliedAt time.Time
+}
+
+// MigrationState is a migration known to this build and whether it has been applied
+type MigrationState struct {
+	Version   string     `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// DataSource supplies the data migrations with what comes from outside the database
+type DataSource interface {
+	// PromptSeeds returns the initial prompt templates: the built-in prompts and the phase and role configs
+	PromptSeeds() ([]PromptSeed, error)
+	// EncryptToken encrypts a stored model token with the configured key; changed is false when the token
+	// is kept as it is, e.g. because no key is configured
+	EncryptToken(token string) (encrypted string, changed bool, err error)
+}
+
+// PromptSeed is version 1 of a prompt template
+type PromptSeed struct {
+	Kind    string
+	Key     string
+	Locale  string
+	Content string
+}
+
+type dataSourceKey struct{}
+
+// dataSource returns the data source MigrateUp was given
+func dataSource(tx *gorm.DB) (DataSource, error) {
+	if data, ok := tx.Statement.Context.Value(dataSourceKey{}).(DataSource); ok && data != nil {
+		return data, nil
+	}
+	return nil, errors.New("migration needs a data source")
+}
+
+func sortedMigrations() []Migration {
+	list := append([]Migration(nil), migrations...)
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list
+}
+
+// appliedMigrations returns the applied versions; a database without schema_migrations has none
+func appliedMigrations(db *gorm.DB) (map[string]SchemaMigration, error) {
+	applied := make(map[string]SchemaMigration)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("load schema migrations failed: %w", err)
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// MigrationStatus lists the migrations of this build, oldest first
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	var states []MigrationState
+	for _, m := range sortedMigrations() {
+		state := MigrationState{Version: m.Version, Name: m.Name}
+		if row, ok := applied[m.Version]; ok {
+			state.Applied = true
+			state.AppliedAt = &row.AppliedAt
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+// MigrateUp applies all pending migrations in version order, each in its own transaction,
+// and returns the ones it applied. data is required while data migrations are pending.
+func MigrateUp(db *gorm.DB, data DataSource) ([]Migration, error) {
+	db = db.WithContext(context.WithValue(db.Statement.Context, dataSourceKey{}, data))
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("create schema_migrations failed: %w", err)
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, m := range sortedMigrations() {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}); err != nil {
+			return done, fmt.Errorf("migration %s_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations, newest first, and returns the ones it reverted
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	list := sortedMigrations()
+	var done []Migration
+	for i := len(list) - 1; i >= 0 && len(done) < steps; i-- {
+		m := list[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		if m.Down == nil {
+			return done, fmt.Errorf("migration %s_%s cannot be reverted", m.Version, m.Name)
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{Version: m.Version}).Error
+		}); err != nil {
+			return done, fmt.Errorf("revert of %s_%s failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// CheckSchema fails when migrations are pending or when the database has migrations this build does not
+// know, which happens when an older build runs against a newer schema
+func CheckSchema(db *gorm.DB) error {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, m := range sortedMigrations() {
+		if _, ok := applied[m.Version]; ok {
+			delete(applied, m.Version)
+		} else {
+			pending++
+		}
+	}
+	for version := range applied {
+		return fmt.Errorf("%w: database has migration %s, which this build does not know", ErrSchemaOutdated, version)
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migrations, run `migrate up` first", ErrSchemaOutdated, pending)
+	}
+	return nil
+}
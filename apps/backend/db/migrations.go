@@ -0,0 +1,537 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrations is the schema history. Append new migrations at the end with the next version;
+// never edit one that has been released.
+var migrations = []Migration{
+	{Version: "0001", Name: "baseline", Up: baselineUp, Down: baselineDown},
+	{Version: "0002", Name: "backfill_task_expense_type", Up: backfillTaskExpenseType, Down: keepData},
+	{Version: "0003", Name: "refingerprint_bills", Up: refingerprintBills, Down: keepData},
+	{Version: "0004", Name: "model_currency_default", Up: modelCurrencyDefault("CNY"), Down: modelCurrencyDefault("USD")},
+	{Version: "0005", Name: "add_task_started_at", Up: addTaskStartedAt, Down: dropTaskStartedAt},
+	{Version: "0006", Name: "create_task_transcripts", Up: createTaskTranscripts, Down: dropTaskTranscripts},
+	{Version: "0007", Name: "add_user_token_version", Up: addUserTokenVersion, Down: dropUserTokenVersion},
+	{Version: "0008", Name: "backfill_project_owners", Up: backfillProjectOwners, Down: keepData},
+	{Version: "0009", Name: "assign_project_organizations", Up: assignProjectOrganizations, Down: keepData},
+	{Version: "0010", Name: "seed_prompt_templates", Up: seedPromptTemplates, Down: keepData},
+	{Version: "0011", Name: "encrypt_model_tokens", Up: encryptModelTokens, Down: keepData},
+}
+
+// baselineTables is the schema as AutoMigrate created it before versioned migrations.
+// The structs are frozen copies of the models at that point, so later model changes do not leak
+// into this migration. On databases created by AutoMigrate it only fills in missing pieces.
+func baselineTables() []interface{} {
+	type TaskResults struct {
+		DemandAnalysis string
+		LanguageChoice string
+		CodeGeneration string
+		ArtDesign      string
+		TestResults    string
+		ReviewComments string
+		FinalCode      string
+	}
+	type Task struct {
+		ID               string `gorm:"primaryKey;size:64"`
+		ProjectID        string `gorm:"index;size:64"`
+		ParentID         string `gorm:"index;size:64"`
+		Name             string
+		Description      string
+		Type             string
+		Status           string
+		Priority         int
+		AssignedRole     string
+		CurrentPhase     string
+		Progress         int
+		Requirements     string
+		Language         string
+		EstimatedDays    int
+		EstimatedCost    float64
+		EstimateModel    string `gorm:"size:128"`
+		CalibratedDays   *float64
+		CalibratedCost   *float64
+		PromptTemplateID uint
+		PromptVersion    int
+		ActualDays       *float64
+		ActualCost       *float64
+		CompletedAt      *time.Time
+		Currency         string `gorm:"size:8;default:'CNY'"`
+		ExpenseType      string `gorm:"default:'budget'"`
+		CreatedAt        time.Time
+		UpdatedAt        time.Time
+		Results          TaskResults `gorm:"embedded;embeddedPrefix:results_"`
+	}
+	type Project struct {
+		ID                string `gorm:"primaryKey;size:64"`
+		Name              string
+		Description       string
+		Organization      string
+		OrganizationID    uint   `gorm:"index"`
+		CreatedBy         uint   `gorm:"index"`
+		Department        string `gorm:"size:64"`
+		Model             string
+		Status            string
+		Vendors           string
+		ReportingCurrency string `gorm:"size:8;default:'CNY'"`
+		Locale            string `gorm:"size:8;default:'zh'"`
+		CreatedAt         time.Time
+		UpdatedAt         time.Time
+		Progress          int
+		Tasks             []Task `gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	}
+	type Model struct {
+		ID               uint   `gorm:"primaryKey"`
+		Name             string `gorm:"size:128;unique;not null"`
+		Provider         string `gorm:"size:32"`
+		BaseURL          string
+		Token            string
+		IsCustom         bool  `gorm:"default:false"`
+		OrganizationID   *uint `gorm:"index"`
+		InputPrice       float64
+		OutputPrice      float64
+		Currency         string `gorm:"size:8;default:'USD'"`
+		StructuredOutput string `gorm:"size:16;default:'text'"`
+		LastCheckAt      *time.Time
+		LastCheckStatus  string `gorm:"size:16"`
+		LastCheckLatency int64  `gorm:"column:last_check_latency_ms"`
+		LastCheckModel   string
+		LastCheckError   string
+		CreatedAt        time.Time
+		UpdatedAt        time.Time
+		DeletedAt        gorm.DeletedAt `gorm:"index"`
+	}
+	type Bill struct {
+		ID          string `gorm:"primaryKey;size:64"`
+		ProjectID   string `gorm:"index;size:64"`
+		Vendor      string `gorm:"index;size:32"`
+		ServiceType string
+		Resource    string
+		PeriodStart time.Time `gorm:"index"`
+		PeriodEnd   time.Time
+		Amount      float64
+		Currency    string `gorm:"size:8"`
+		Tags        string
+		ImportID    string `gorm:"index;size:64"`
+		Fingerprint string `gorm:"uniqueIndex;size:64"`
+		CreatedAt   time.Time
+		UpdatedAt   time.Time
+	}
+	type BillRule struct {
+		ID        uint   `gorm:"primaryKey"`
+		ProjectID string `gorm:"index;size:64;not null"`
+		Vendor    string `gorm:"size:32"`
+		TagKey    string
+		TagValue  string
+		Field     string
+		Pattern   string
+		Priority  int
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type ExchangeRate struct {
+		ID            uint      `gorm:"primaryKey"`
+		BaseCurrency  string    `gorm:"size:8;not null;uniqueIndex:idx_exchange_rate_pair_date"`
+		QuoteCurrency string    `gorm:"size:8;not null;uniqueIndex:idx_exchange_rate_pair_date"`
+		EffectiveDate time.Time `gorm:"not null;uniqueIndex:idx_exchange_rate_pair_date"`
+		Rate          float64   `gorm:"not null"`
+		Source        string
+		CreatedAt     time.Time
+		UpdatedAt     time.Time
+	}
+	type TaskPlanPreview struct {
+		ID        string `gorm:"primaryKey;size:64"`
+		ProjectID string `gorm:"index;size:64"`
+		Status    string
+		Changes   []string `gorm:"serializer:json"`
+		Unchanged []string `gorm:"serializer:json"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type PromptTemplate struct {
+		ID        uint   `gorm:"primaryKey"`
+		Kind      string `gorm:"size:32;uniqueIndex:idx_prompt_version"`
+		Key       string `gorm:"column:prompt_key;size:128;uniqueIndex:idx_prompt_version"`
+		Locale    string `gorm:"size:8;default:'zh';uniqueIndex:idx_prompt_version"`
+		Version   int    `gorm:"uniqueIndex:idx_prompt_version"`
+		Content   string `gorm:"type:text"`
+		Author    string
+		Note      string
+		Active    bool `gorm:"index"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type User struct {
+		ID           uint   `gorm:"primaryKey"`
+		Username     string `gorm:"size:64;uniqueIndex;not null"`
+		PasswordHash string `gorm:"not null"`
+		Role         string `gorm:"size:16;default:'user'"`
+		Department   string `gorm:"size:64"`
+		Active       bool   `gorm:"default:true"`
+		LastLoginAt  *time.Time
+		CreatedAt    time.Time
+		UpdatedAt    time.Time
+		DeletedAt    gorm.DeletedAt `gorm:"index"`
+	}
+	type Organization struct {
+		ID        uint   `gorm:"primaryKey"`
+		Name      string `gorm:"size:128;uniqueIndex;not null"`
+		CreatedAt time.Time
+		UpdatedAt time.Time
+	}
+	type OrganizationMember struct {
+		ID             uint   `gorm:"primaryKey"`
+		OrganizationID uint   `gorm:"uniqueIndex:idx_org_member;not null"`
+		UserID         uint   `gorm:"uniqueIndex:idx_org_member;index;not null"`
+		Role           string `gorm:"size:16;not null"`
+		CreatedAt      time.Time
+		UpdatedAt      time.Time
+	}
+	type APIKey struct {
+		ID         uint   `gorm:"primaryKey"`
+		Name       string `gorm:"size:128;not null"`
+		Prefix     string `gorm:"size:16"`
+		Hash       string `gorm:"size:64;uniqueIndex;not null"`
+		Scope      string `gorm:"size:16;not null"`
+		UserID     uint   `gorm:"index;not null"`
+		ExpiresAt  *time.Time
+		LastUsedAt *time.Time
+		RevokedAt  *time.Time
+		CreatedAt  time.Time
+	}
+	type AuditLog struct {
+		ID         uint   `gorm:"primaryKey"`
+		ActorID    uint   `gorm:"index"`
+		Actor      string `gorm:"size:64"`
+		APIKeyID   uint
+		Action     string            `gorm:"size:16;index"`
+		EntityType string            `gorm:"size:16;index:idx_audit_entity"`
+		EntityID   string            `gorm:"size:64;index:idx_audit_entity"`
+		ProjectID  string            `gorm:"size:64;index"`
+		Changes    map[string]string `gorm:"serializer:json"`
+		RequestID  string            `gorm:"size:64;index"`
+		CreatedAt  time.Time         `gorm:"index"`
+	}
+	return []interface{}{&Project{}, &Task{}, &Model{}, &Bill{}, &BillRule{}, &ExchangeRate{}, &TaskPlanPreview{},
+		&PromptTemplate{}, &User{}, &Organization{}, &OrganizationMember{}, &APIKey{}, &AuditLog{}}
+}
+
+func baselineUp(tx *gorm.DB) error {
+	return tx.AutoMigrate(baselineTables()...)
+}
+
+// baselineDown drops every table, children before parents
+func baselineDown(tx *gorm.DB) error {
+	tables := baselineTables()
+	for i := len(tables) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(tables[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillTaskExpenseType sets the expense type of tasks from before the column had a default.
+// AutoMigrate added the column without touching existing rows, so they were excluded from budgets.
+func backfillTaskExpenseType(tx *gorm.DB) error {
+	return tx.Exec("UPDATE tasks SET expense_type = ? WHERE expense_type IS NULL OR expense_type = ''", "budget").Error
+}
+
+// refingerprintBills recomputes bill fingerprints from the normalized fields instead of the raw CSV
+// record, so exports whose columns changed are recognised as duplicates of earlier imports.
+// Lines that already collide with an earlier bill keep their old fingerprint rather than being deleted.
+func refingerprintBills(tx *gorm.DB) error {
+	type bill struct {
+		ID          string
+		Vendor      string
+		ServiceType string
+		Resource    string
+		PeriodStart time.Time
+		PeriodEnd   time.Time
+		Amount      float64
+		Currency    string
+		ImportID    string
+	}
+	var bills []bill
+	if err := tx.Table("bills").Order("import_id, created_at, id").Find(&bills).Error; err != nil {
+		return err
+	}
+	seen := map[string]int{}
+	taken := map[string]bool{}
+	for _, b := range bills {
+		// Frozen copy of models.Bill.DedupeKey and models.BillFingerprint
+		key := strings.Join([]string{b.Vendor, b.Resource, b.ServiceType, b.PeriodStart.UTC().Format(time.RFC3339),
+			b.PeriodEnd.UTC().Format(time.RFC3339), strconv.FormatFloat(b.Amount, 'f', -1, 64), b.Currency}, "\x1f")
+		seen[b.ImportID+"\x1f"+key]++
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x1f%d", key, seen[b.ImportID+"\x1f"+key])))
+		fingerprint := hex.EncodeToString(sum[:])
+		if taken[fingerprint] {
+			continue
+		}
+		taken[fingerprint] = true
+		if err := tx.Table("bills").Where("id = ?", b.ID).Update("fingerprint", fingerprint).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// modelCurrencyDefault sets the column default of models.currency, which the baseline created as USD
+// while prices without a currency are meant to be in models.DefaultCurrency. SQLite cannot change a
+// column default; the application always writes the column, so the old default is never used there.
+func modelCurrencyDefault(currency string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "sqlite" {
+			return nil
+		}
+		return tx.Exec(fmt.Sprintf("ALTER TABLE models ALTER COLUMN currency SET DEFAULT '%s'", currency)).Error
+	}
+}
+
+// taskStartedAt is the column added by 0005
+type taskStartedAt struct {
+	StartedAt *time.Time
+}
+
+// addTaskStartedAt adds the time a task's execution first started, from which its actual days are measured
+func addTaskStartedAt(tx *gorm.DB) error {
+	m := tx.Table("tasks").Migrator()
+	if m.HasColumn(&taskStartedAt{}, "StartedAt") {
+		return nil
+	}
+	return m.AddColumn(&taskStartedAt{}, "StartedAt")
+}
+
+func dropTaskStartedAt(tx *gorm.DB) error {
+	return tx.Table("tasks").Migrator().DropColumn(&taskStartedAt{}, "StartedAt")
+}
+
+// taskTranscript is the table created by 0006; transcripts are removed with their task
+type taskTranscript struct {
+	ID               uint           `gorm:"primaryKey"`
+	TaskID           string         `gorm:"index;size:64"`
+	Task             transcriptTask `gorm:"constraint:OnDelete:CASCADE"`
+	ProjectID        string         `gorm:"index;size:64"`
+	Phase            string         `gorm:"size:64"`
+	Role             string         `gorm:"size:128"`
+	Prompt           string         `gorm:"type:text"`
+	RolePrompt       string         `gorm:"type:text"`
+	Output           string         `gorm:"type:text"`
+	PromptTemplateID uint
+	PromptVersion    int
+	RoleTemplateID   uint
+	RoleVersion      int
+	CreatedAt        time.Time
+}
+
+// transcriptTask is the referenced side of the transcript foreign key
+type transcriptTask struct {
+	ID string `gorm:"primaryKey;size:64"`
+}
+
+func (transcriptTask) TableName() string {
+	return "tasks"
+}
+
+func createTaskTranscripts(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&taskTranscript{})
+}
+
+func dropTaskTranscripts(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&taskTranscript{})
+}
+
+// userTokenVersion is the column added by 0007
+type userTokenVersion struct {
+	TokenVersion int `gorm:"not null;default:0"`
+}
+
+// addUserTokenVersion adds the counter that refresh tokens are checked against, so they can be revoked
+func addUserTokenVersion(tx *gorm.DB) error {
+	m := tx.Table("users").Migrator()
+	if m.HasColumn(&userTokenVersion{}, "TokenVersion") {
+		return nil
+	}
+	return m.AddColumn(&userTokenVersion{}, "TokenVersion")
+}
+
+func dropUserTokenVersion(tx *gorm.DB) error {
+	return tx.Table("users").Migrator().DropColumn(&userTokenVersion{}, "TokenVersion")
+}
+
+// backfillProjectOwners assigns projects from before the data scopes, which have no creator, to the first
+// owner of their organization and stamps that owner's department where none is set. Without it such
+// projects are invisible to every member limited to the own or department scope.
+func backfillProjectOwners(tx *gorm.DB) error {
+	var projects []struct {
+		ID             string
+		OrganizationID uint
+		Department     string
+	}
+	if err := tx.Table("projects").Where("created_by = 0 OR created_by IS NULL").Find(&projects).Error; err != nil {
+		return err
+	}
+	type owner struct {
+		UserID     uint
+		Department string
+	}
+	owners := map[uint]*owner{}
+	for _, p := range projects {
+		o, ok := owners[p.OrganizationID]
+		if !ok {
+			var found []owner
+			if err := tx.Table("organization_members").
+				Select("organization_members.user_id, users.department").
+				Joins("JOIN users ON users.id = organization_members.user_id AND users.deleted_at IS NULL").
+				Where("organization_members.organization_id = ? AND organization_members.role = ?", p.OrganizationID, "owner").
+				Order("organization_members.id").Limit(1).Scan(&found).Error; err != nil {
+				return err
+			}
+			if len(found) > 0 {
+				o = &found[0]
+			}
+			owners[p.OrganizationID] = o
+		}
+		if o == nil {
+			continue
+		}
+		updates := map[string]interface{}{"created_by": o.UserID}
+		if p.Department == "" {
+			updates["department"] = o.Department
+		}
+		if err := tx.Table("projects").Where("id = ?", p.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedOrganization is an organizations row as created by 0009
+type seedOrganization struct {
+	ID        uint
+	Name      string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (seedOrganization) TableName() string {
+	return "organizations"
+}
+
+// assignProjectOrganizations turns the free-text organization of projects created before tenants existed
+// into organizations, creating one per distinct name. Projects without a name go to "Default".
+// System admins see these organizations; members are added afterwards.
+func assignProjectOrganizations(tx *gorm.DB) error {
+	var names []string
+	if err := tx.Table("projects").Where("organization_id = 0 OR organization_id IS NULL").
+		Distinct().Pluck("COALESCE(organization, '')", &names).Error; err != nil {
+		return err
+	}
+	for _, name := range names {
+		orgName := strings.TrimSpace(name)
+		if orgName == "" {
+			orgName = "Default"
+		}
+		org := seedOrganization{Name: orgName}
+		if err := tx.Where("name = ?", orgName).FirstOrCreate(&org).Error; err != nil {
+			return fmt.Errorf("create organization %s failed: %w", orgName, err)
+		}
+		if err := tx.Table("projects").
+			Where("(organization_id = 0 OR organization_id IS NULL) AND COALESCE(organization, '') = ?", name).
+			Updates(map[string]interface{}{"organization_id": org.ID, "organization": orgName}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedPromptTemplate is a prompt_templates row as created by 0010
+type seedPromptTemplate struct {
+	ID        uint
+	Kind      string
+	Key       string `gorm:"column:prompt_key"`
+	Locale    string
+	Version   int
+	Content   string
+	Author    string
+	Note      string
+	Active    bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (seedPromptTemplate) TableName() string {
+	return "prompt_templates"
+}
+
+// seedPromptTemplates creates an active version 1 of every prompt of the data source that has no versions
+// yet. Prompts added to the configs later need a migration of their own or are created through the API.
+func seedPromptTemplates(tx *gorm.DB) error {
+	data, err := dataSource(tx)
+	if err != nil {
+		return err
+	}
+	seeds, err := data.PromptSeeds()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, seed := range seeds {
+		var count int64
+		if err := tx.Model(&seedPromptTemplate{}).Where("kind = ? AND prompt_key = ? AND locale = ?", seed.Kind, seed.Key, seed.Locale).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if err := tx.Create(&seedPromptTemplate{Kind: seed.Kind, Key: seed.Key, Locale: seed.Locale, Version: 1, Content: seed.Content,
+			Author: "system", Note: "initial version", Active: true, CreatedAt: now, UpdatedAt: now}).Error; err != nil {
+			return fmt.Errorf("seed %s prompt %s %s failed: %w", seed.Locale, seed.Kind, seed.Key, err)
+		}
+	}
+	return nil
+}
+
+// encryptModelTokens encrypts the model tokens stored in plain text before token encryption existed.
+// Without a key it changes nothing; tokens stored before a key is set later are encrypted by
+// `neuro-dev rotate-token-key`.
+func encryptModelTokens(tx *gorm.DB) error {
+	data, err := dataSource(tx)
+	if err != nil {
+		return err
+	}
+	var rows []struct {
+		ID    uint
+		Token string
+	}
+	if err := tx.Table("models").Where("token <> ''").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		token, changed, err := data.EncryptToken(row.Token)
+		if err != nil {
+			return fmt.Errorf("model %d: %w", row.ID, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := tx.Table("models").Where("id = ?", row.ID).Update("token", token).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepData is the down step of data-only migrations whose result stays valid after reverting
+func keepData(tx *gorm.DB) error {
+	return nil
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQuery is the duration above which statements are logged at warn level
+const slowQuery = 200 * time.Millisecond
+
+// Gorm returns a GORM logger writing to the default slog logger. Every statement is logged at debug level
+// when enableDB is set (settings.logger.enableddb); otherwise only failed and slow statements are.
+func Gorm(enableDB bool) gormlogger.Interface {
+	return &gormLogger{all: enableDB}
+}
+
+type gormLogger struct {
+	all bool
+}
+
+func (l *gormLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &gormLogger{all: level >= gormlogger.Info}
+}
+
+func (l *gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	slog.InfoContext(ctx, msg, "args", args)
+}
+
+func (l *gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	slog.WarnContext(ctx, msg, "args", args)
+}
+
+func (l *gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	slog.ErrorContext(ctx, msg, "args", args)
+}
+
+func (l *gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	level := slog.LevelDebug
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level = slog.LevelError
+	case elapsed > slowQuery:
+		level = slog.LevelWarn
+	case !l.all:
+		return
+	}
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+	sql, rows := fc()
+	args := []any{"sql", sql, "rows", rows, "elapsed", elapsed}
+	if err != nil {
+		args = append(args, "error", err)
+	}
+	slog.Log(ctx, level, "sql", args...)
+}
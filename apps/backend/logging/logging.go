@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+	"neuro-dev/config"
+)
+
+// Levels of settings.logger.level that slog does not define
+const (
+	LevelTrace = slog.Level(-8)
+	LevelFatal = slog.Level(12)
+)
+
+// FileName is the log file written below settings.logger.path when stdout is file
+const FileName = "neuro-dev.log"
+
+// Rotation defaults for settings.logger values left at 0
+const (
+	defaultMaxSizeMB  = 100
+	defaultMaxBackups = 10
+	defaultMaxAgeDays = 30
+)
+
+var levels = map[string]slog.Level{
+	"trace": LevelTrace,
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+	"fatal": LevelFatal,
+}
+
+// ParseLevel converts trace, debug, info, warn, error or fatal to a slog level; empty is info
+func ParseLevel(level string) (slog.Level, error) {
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	l, ok := levels[strings.ToLower(level)]
+	if !ok {
+		return 0, fmt.Errorf("invalid log level %q, expected trace, debug, info, warn, error or fatal", level)
+	}
+	return l, nil
+}
+
+// Setup installs the default logger described by settings.logger. Records go to a rotated file below
+// cfg.Path when cfg.Stdout is file and to stdout otherwise; log.Printf output goes through the same logger.
+// The returned closer flushes and closes the log file.
+func Setup(cfg config.Logger) (io.Closer, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	var out io.WriteCloser = nopCloser{os.Stdout}
+	if cfg.Stdout == "file" {
+		if err := os.MkdirAll(cfg.Path, 0o755); err != nil {
+			return nil, fmt.Errorf("create log directory failed: %w", err)
+		}
+		out = &lumberjack.Logger{
+			Filename:   filepath.Join(cfg.Path, FileName),
+			MaxSize:    orDefault(cfg.MaxSize, defaultMaxSizeMB),
+			MaxBackups: orDefault(cfg.MaxBackups, defaultMaxBackups),
+			MaxAge:     orDefault(cfg.MaxAge, defaultMaxAgeDays),
+			Compress:   cfg.Compress,
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: levelNames}
+	var h slog.Handler
+	if cfg.Format == "json" {
+		h = slog.NewJSONHandler(out, opts)
+	} else {
+		h = slog.NewTextHandler(out, opts)
+	}
+	slog.SetDefault(slog.New(contextHandler{h}))
+	return out, nil
+}
+
+// Fatal logs at fatal level and exits
+func Fatal(msg string, args ...any) {
+	slog.Log(context.Background(), LevelFatal, msg, args...)
+	os.Exit(1)
+}
+
+// levelNames prints TRACE and FATAL instead of DEBUG-4 and ERROR+4
+func levelNames(_ []string, a slog.Attr) slog.Attr {
+	if a.Key != slog.LevelKey {
+		return a
+	}
+	switch a.Value.Any().(slog.Level) {
+	case LevelTrace:
+		a.Value = slog.StringValue("TRACE")
+	case LevelFatal:
+		a.Value = slog.StringValue("FATAL")
+	}
+	return a
+}
+
+func orDefault(v, def int) int {
+	if v > 0 {
+		return v
+	}
+	return def
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+type attrsKey struct{}
+
+// With returns a context whose log records carry args, given as key-value pairs or slog.Attr, in addition
+// to the attributes already on ctx. Use it for request, project and task IDs.
+func With(ctx context.Context, args ...any) context.Context {
+	prev, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	r := slog.Record{}
+	r.Add(args...)
+	attrs := make([]slog.Attr, len(prev), len(prev)+r.NumAttrs())
+	copy(attrs, prev)
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return context.WithValue(ctx, attrsKey{}, attrs)
+}
+
+// contextHandler adds the attributes stored by With to every record logged with that context
+type contextHandler struct{ slog.Handler }
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
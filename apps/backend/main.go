@@ -1,38 +1,113 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/rs/cors"
+	"neuro-dev/config"
 	"neuro-dev/controllers"
+	"neuro-dev/logging"
+	"neuro-dev/metrics"
 )
 
+const defaultPort = 8080
+
 func main() {
-	// Allow overriding settings path from env if running from different working dir
-	if os.Getenv("NEURO_SETTINGS_PATH") == "" {
-		if _, err := os.Stat("./config/settings.yml"); err != nil {
-			// try default relative to apps/backend
-			_ = os.Setenv("NEURO_SETTINGS_PATH", "D:/code-work/go/work-space/neuro-dev/apps/backend/config/settings.yml")
+	if len(os.Args) > 1 {
+		cmd, ok := commands[os.Args[1]]
+		if !ok {
+			log.Fatalf("unknown command: %s", os.Args[1])
+		}
+		if err := cmd(); err != nil {
+			log.Fatal(err)
 		}
+		return
 	}
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
 	}
+	logFile, err := logging.Setup(cfg.Logger)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer logFile.Close()
 
-	s := controllers.NewServer()
+	s := controllers.NewServer(cfg)
 
 	c := cors.New(cors.Options{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"*"},
 	})
-	handler := c.Handler(s.Router)
 
-	fmt.Printf("Server starting on port %s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, handler))
+	app := cfg.Application
+	port := app.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	srv := &http.Server{
+		Addr:         net.JoinHostPort(app.Host, strconv.Itoa(port)),
+		Handler:      c.Handler(s.Router),
+		ReadTimeout:  time.Duration(app.ReadTimeout) * time.Second,
+		WriteTimeout: time.Duration(app.WriteTimeout) * time.Second,
+	}
+
+	// Metrics have their own port so they can be scraped inside the cluster without being exposed with the API
+	var metricsSrv *http.Server
+	if app.MetricsPort > 0 {
+		metricsSrv = &http.Server{
+			Addr:              net.JoinHostPort(app.Host, strconv.Itoa(app.MetricsPort)),
+			Handler:           metrics.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		slog.Info("Server starting", "addr", srv.Addr, "mode", app.Mode)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logging.Fatal("Server failed", "error", err)
+		}
+	}()
+	if metricsSrv != nil {
+		go func() {
+			slog.Info("Metrics server starting", "addr", metricsSrv.Addr)
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logging.Fatal("Metrics server failed", "error", err)
+			}
+		}()
+	}
+	<-ctx.Done()
+	stop()
+
+	// Stop accepting requests and let running ones finish, then let tasks checkpoint and close the rest
+	timeout := app.ShutdownDuration()
+	slog.Info("Shutting down", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server shutdown failed", "error", err)
+	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("Metrics server shutdown failed", "error", err)
+		}
+	}
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		logging.Fatal("Shutdown failed", "error", err)
+	}
+	slog.Info("Server stopped")
 }
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+// namespace prefixes every metric, e.g. neuro_http_requests_total
+const namespace = "neuro"
+
+// HTTP metrics, labelled by the route template such as /api/projects/{id} rather than the path
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method and route, WebSocket connections excluded.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+	WebSockets = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "websocket_connections",
+		Help:      "Open WebSocket connections.",
+	})
+)
+
+// Task execution metrics
+var (
+	TasksRunning = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "tasks",
+		Name:      "running",
+		Help:      "Task executions running in this process.",
+	})
+	PhaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "tasks",
+		Name:      "phase_duration_seconds",
+		Help:      "Duration of finished task phases.",
+		Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+	}, []string{"phase"})
+)
+
+// LLM metrics; result is ok or error, type is prompt or completion
+var (
+	LLMRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "requests_total",
+		Help:      "LLM calls by provider, model and result.",
+	}, []string{"provider", "model", "result"})
+	LLMDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "request_duration_seconds",
+		Help:      "LLM call latency by provider and model.",
+		Buckets:   prometheus.ExponentialBuckets(0.25, 2, 11),
+	}, []string{"provider", "model"})
+	LLMTokens = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "llm",
+		Name:      "tokens_total",
+		Help:      "Tokens reported by the provider by model and type.",
+	}, []string{"provider", "model", "type"})
+)
+
+// Handler serves GET /metrics. It is meant for the internal metrics listener, not the public API port,
+// since it requires no authentication.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return mux
+}
+
+// RegisterDB exports the connection pool stats of db and the number of tasks in each status
+func RegisterDB(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	if err := prometheus.Register(collectors.NewDBStatsCollector(sqlDB, namespace)); err != nil {
+		return err
+	}
+	return prometheus.Register(&taskCollector{db: db})
+}
+
+var tasksDesc = prometheus.NewDesc(prometheus.BuildFQName(namespace, "tasks", "total"),
+	"Tasks by status; pending tasks are queued.", []string{"status"}, nil)
+
+// taskCollector counts tasks by status when scraped
+type taskCollector struct {
+	db *gorm.DB
+}
+
+func (c *taskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tasksDesc
+}
+
+func (c *taskCollector) Collect(ch chan<- prometheus.Metric) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := c.db.Table("tasks").Select("status, COUNT(*) AS count").Group("status").Scan(&rows).Error; err != nil {
+		ch <- prometheus.NewInvalidMetric(tasksDesc, err)
+		return
+	}
+	for _, r := range rows {
+		ch <- prometheus.MustNewConstMetric(tasksDesc, prometheus.GaugeValue, float64(r.Count), r.Status)
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// API key scopes, from least to most privileged
+const (
+	APIKeyScopeRead         = "read"          // GET requests only
+	APIKeyScopeProjectWrite = "project-write" // also creates and changes projects and tasks
+	APIKeyScopeAdmin        = "admin"         // everything the owning admin can do
+)
+
+// APIKey lets automation call the API as its owner without a login.
+// Only the SHA-256 of the key is stored; the key itself is returned once on creation.
+type APIKey struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	Name       string     `json:"name" gorm:"size:128;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:16"` // first characters of the key, to recognise it in listings
+	Hash       string     `json:"-" gorm:"size:64;uniqueIndex;not null"`
+	Scope      string     `json:"scope" gorm:"size:16;not null"`
+	UserID     uint       `json:"user_id" gorm:"index;not null"` // the key acts with this user's organizations
+	ExpiresAt  *time.Time `json:"expires_at"`                    // nil never expires
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// APIKeyResponse is returned on creation and is the only time the key is shown
+type APIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditAppendOnly is returned when an audit entry would be changed or removed
+var ErrAuditAppendOnly = errors.New("audit log is append-only")
+
+// Audit actions
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Audited entity types
+const (
+	AuditEntityProject = "project"
+	AuditEntityTask    = "task"
+	AuditEntityModel   = "model"
+)
+
+// AuditLog records one change made through the API. Rows are only ever inserted.
+type AuditLog struct {
+	ID         uint                   `json:"id" gorm:"primaryKey"`
+	ActorID    uint                   `json:"actor_id" gorm:"index"`
+	Actor      string                 `json:"actor" gorm:"size:64"` // username at the time of the change
+	APIKeyID   uint                   `json:"api_key_id,omitempty"` // set when the change was made with an API key
+	Action     string                 `json:"action" gorm:"size:16;index"`
+	EntityType string                 `json:"entity_type" gorm:"size:16;index:idx_audit_entity"`
+	EntityID   string                 `json:"entity_id" gorm:"size:64;index:idx_audit_entity"`
+	ProjectID  string                 `json:"project_id,omitempty" gorm:"size:64;index"` // project of the entity, used to scope queries
+	Changes    map[string]AuditChange `json:"changes" gorm:"serializer:json"`            // changed fields only
+	RequestID  string                 `json:"request_id" gorm:"size:64;index"`
+	CreatedAt  time.Time              `json:"created_at" gorm:"index"`
+}
+
+// BeforeUpdate keeps recorded entries unchanged
+func (AuditLog) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// BeforeDelete keeps recorded entries from being removed
+func (AuditLog) BeforeDelete(*gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+// AuditChange is the value of one field before and after a change; nil for created or deleted entities
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditQuery filters the audit log; empty fields match everything
+type AuditQuery struct {
+	EntityType string
+	EntityID   string
+	ProjectID  string
+	Actor      string
+	Action     string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
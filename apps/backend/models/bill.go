@@ -0,0 +1,79 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Bill is a normalized line item imported from a cloud vendor billing export
+// Fingerprint is unique so that re-importing the same export does not duplicate lines
+type Bill struct {
+	ID          string    `json:"id" gorm:"primaryKey;size:64"`
+	ProjectID   string    `json:"project_id" gorm:"index;size:64"`
+	Vendor      string    `json:"vendor" gorm:"index;size:32"` // aliyun, huaweicloud, aws, gcp
+	ServiceType string    `json:"service_type"`
+	Resource    string    `json:"resource"`
+	PeriodStart time.Time `json:"period_start" gorm:"index"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Amount      float64   `json:"amount"`
+	Currency    string    `json:"currency" gorm:"size:8"`
+	Tags        string    `json:"tags"` // normalized as k=v;k=v
+	ImportID    string    `json:"import_id" gorm:"index;size:64"`
+	Fingerprint string    `json:"-" gorm:"uniqueIndex;size:64"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// DedupeKey identifies a line by its normalized fields, so exports whose columns were added or
+// reordered by the vendor produce the same key for the same charge
+func (b *Bill) DedupeKey() string {
+	return strings.Join([]string{
+		b.Vendor,
+		b.Resource,
+		b.ServiceType,
+		b.PeriodStart.UTC().Format(time.RFC3339),
+		b.PeriodEnd.UTC().Format(time.RFC3339),
+		strconv.FormatFloat(b.Amount, 'f', -1, 64),
+		b.Currency,
+	}, "\x1f")
+}
+
+// BillFingerprint hashes a dedupe key with its occurrence number within one export, so identical
+// lines of the same export are kept apart while re-imports produce the same fingerprints
+func BillFingerprint(key string, occurrence int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x1f%d", key, occurrence)))
+	return hex.EncodeToString(sum[:])
+}
+
+// BillRule maps imported bill lines to a project
+// A rule matches when the vendor matches (empty matches any vendor) and either
+// the tag TagKey equals TagValue, or Field (service_type/resource) contains Pattern.
+type BillRule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	ProjectID string    `json:"project_id" gorm:"index;size:64;not null"`
+	Vendor    string    `json:"vendor" gorm:"size:32"`
+	TagKey    string    `json:"tag_key"`
+	TagValue  string    `json:"tag_value"`
+	Field     string    `json:"field"`
+	Pattern   string    `json:"pattern"`
+	Priority  int       `json:"priority"` // lower value is evaluated first
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BillImportResult summarizes a single CSV import
+type BillImportResult struct {
+	ImportID   string   `json:"import_id"`
+	Vendor     string   `json:"vendor"`
+	TotalRows  int      `json:"total_rows"`
+	Imported   int      `json:"imported"`
+	Duplicates int      `json:"duplicates"`
+	Skipped    int      `json:"skipped"`
+	Mapped     int      `json:"mapped"`
+	Unmapped   int      `json:"unmapped"`
+	Errors     []string `json:"errors,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// EstimationFactor is the ratio of actual to estimated effort observed for one scope.
+// Scope is "type_model", "type", "model" or "global"; a factor above 1 means estimates run low.
+type EstimationFactor struct {
+	Scope      string  `json:"scope"`
+	TaskType   string  `json:"task_type,omitempty"`
+	Model      string  `json:"model,omitempty"`
+	Samples    int     `json:"samples"`
+	DaysFactor float64 `json:"days_factor"`
+	CostFactor float64 `json:"cost_factor"`
+}
+
+// EstimationAccuracyRow summarises estimation errors of completed tasks for one task type and model.
+// Errors are mean absolute percentage errors against the recorded actuals; calibrated errors only
+// cover tasks that had a calibrated estimate.
+type EstimationAccuracyRow struct {
+	TaskType            string   `json:"task_type"`
+	Model               string   `json:"model"`
+	Samples             int      `json:"samples"`
+	DaysError           float64  `json:"days_error"`
+	CostError           *float64 `json:"cost_error"`
+	CalibratedSamples   int      `json:"calibrated_samples"`
+	CalibratedDaysError *float64 `json:"calibrated_days_error"`
+	CalibratedCostError *float64 `json:"calibrated_cost_error"`
+	DaysFactor          float64  `json:"days_factor"`
+	CostFactor          float64  `json:"cost_factor"`
+}
+
+type EstimationAccuracyReport struct {
+	ProjectID   string                  `json:"project_id,omitempty"`
+	Rows        []EstimationAccuracyRow `json:"rows"`
+	Factors     []EstimationFactor      `json:"factors"`
+	MinSamples  int                     `json:"min_samples"`
+	GeneratedAt time.Time               `json:"generated_at"`
+}
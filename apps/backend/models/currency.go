@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// DefaultCurrency is used for projects and tasks that do not specify a currency
+const DefaultCurrency = "CNY"
+
+// ExchangeRate stores how many units of QuoteCurrency one unit of BaseCurrency buys on EffectiveDate
+type ExchangeRate struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	BaseCurrency  string    `json:"base_currency" gorm:"size:8;not null;uniqueIndex:idx_exchange_rate_pair_date"`
+	QuoteCurrency string    `json:"quote_currency" gorm:"size:8;not null;uniqueIndex:idx_exchange_rate_pair_date"`
+	EffectiveDate time.Time `json:"effective_date" gorm:"not null;uniqueIndex:idx_exchange_rate_pair_date"`
+	Rate          float64   `json:"rate" gorm:"not null"`
+	Source        string    `json:"source"` // manual, import
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
@@ -6,24 +6,67 @@ import (
 )
 
 type Model struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Name      string         `json:"name" gorm:"unique;not null"`
-	BaseURL   string         `json:"base_url"`
-	Token     string         `json:"token,omitempty"`
-	IsCustom  bool           `json:"is_custom" gorm:"default:false"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID               uint           `json:"id" gorm:"primaryKey"`
+	Name             string         `json:"name" gorm:"size:128;unique;not null"`
+	Provider         string         `json:"provider" gorm:"size:32"` // openai, anthropic, ollama or cohere; empty uses settings.llm.provider
+	BaseURL          string         `json:"base_url"`
+	Token            string         `json:"-"`                      // envelope encrypted, see services.TokenCipher
+	TokenPreview     string         `json:"token_preview" gorm:"-"` // masked token for API responses
+	IsCustom         bool           `json:"is_custom" gorm:"default:false"`
+	OrganizationID   *uint          `json:"organization_id" gorm:"index"` // owning organization; nil models are shared by all organizations
+	InputPrice       float64        `json:"input_price"`                  // per million tokens, in Currency
+	OutputPrice      float64        `json:"output_price"`                 // per million tokens, in Currency
+	Currency         string         `json:"currency" gorm:"size:8"`
+	StructuredOutput string         `json:"structured_output" gorm:"size:16;default:'text'"` // text, json_object, json_schema or function
+	LastCheckAt      *time.Time     `json:"last_check_at"`
+	LastCheckStatus  string         `json:"last_check_status" gorm:"size:16"` // ok, auth, not_found, network, quota or unknown
+	LastCheckLatency int64          `json:"last_check_latency_ms" gorm:"column:last_check_latency_ms"`
+	LastCheckModel   string         `json:"last_check_model"` // provider/model the check was sent to
+	LastCheckError   string         `json:"last_check_error"`
+	CreatedAt        time.Time      `json:"created_at"`
+	UpdatedAt        time.Time      `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// BeforeSave prices models without a currency in DefaultCurrency
+func (m *Model) BeforeSave(*gorm.DB) error {
+	if m.Currency == "" {
+		m.Currency = DefaultCurrency
+	}
+	return nil
 }
 
 type CreateModelRequest struct {
-	Name    string `json:"name" binding:"required"`
-	BaseURL string `json:"base_url"`
-	Token   string `json:"token"`
+	Name             string  `json:"name" binding:"required"`
+	OrganizationID   *uint   `json:"organization_id"` // omit for a shared model, which only admins may create
+	Provider         string  `json:"provider"`
+	BaseURL          string  `json:"base_url"`
+	Token            string  `json:"token"`
+	InputPrice       float64 `json:"input_price"`
+	OutputPrice      float64 `json:"output_price"`
+	Currency         string  `json:"currency"`
+	StructuredOutput string  `json:"structured_output"`
 }
 
 type UpdateModelRequest struct {
-	Name    string `json:"name"`
-	BaseURL string `json:"base_url"`
-	Token   string `json:"token"`
+	Name             string   `json:"name"`
+	Provider         string   `json:"provider"`
+	BaseURL          string   `json:"base_url"`
+	Token            string   `json:"token"`
+	InputPrice       *float64 `json:"input_price"`
+	OutputPrice      *float64 `json:"output_price"`
+	Currency         string   `json:"currency"`
+	StructuredOutput string   `json:"structured_output"`
+}
+
+// ModelCheckResult is the outcome of a connectivity check of a model
+type ModelCheckResult struct {
+	ModelID       uint      `json:"model_id"`
+	Model         string    `json:"model"`
+	ResolvedModel string    `json:"resolved_model"` // provider/model the request was sent to
+	OK            bool      `json:"ok"`
+	Status        string    `json:"status"` // ok, auth, not_found, network, quota or unknown
+	LatencyMs     int64     `json:"latency_ms"`
+	Error         string    `json:"error,omitempty"`
+	CheckedAt     time.Time `json:"checked_at"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Organization roles, from most to least privileged
+const (
+	OrgRoleOwner   = "owner"   // manages members and may delete projects and models
+	OrgRoleManager = "manager" // creates and edits projects, tasks, models and bill rules
+	OrgRoleViewer  = "viewer"  // read only
+)
+
+// Organization is a tenant; projects and custom models belong to exactly one
+type Organization struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"size:128;uniqueIndex;not null"`
+	Role      string    `json:"role,omitempty" gorm:"-"` // caller's role, filled in listings
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OrganizationMember grants a user a role in an organization
+type OrganizationMember struct {
+	ID             uint      `json:"id" gorm:"primaryKey"`
+	OrganizationID uint      `json:"organization_id" gorm:"uniqueIndex:idx_org_member;not null"`
+	UserID         uint      `json:"user_id" gorm:"uniqueIndex:idx_org_member;index;not null"`
+	Username       string    `json:"username" gorm:"-"`
+	Role           string    `json:"role" gorm:"size:16;not null"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
@@ -5,16 +5,22 @@ import "time"
 // Project represents a project entity
 // GORM: use string ID as primary key
 type Project struct {
-	ID            string    `json:"id" gorm:"primaryKey;size:64"`
-	Name          string    `json:"name"`
-	Description   string    `json:"description"`
-	Organization  string    `json:"organization"`
-	Model         string    `json:"model"`
-	Status        string    `json:"status"`
-	Vendors       string    `json:"vendors"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	Progress      int       `json:"progress"`
-	EstimatedCost float64   `json:"estimated_cost" gorm:"-"`
-	Tasks         []Task    `json:"tasks" gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
+	ID                string    `json:"id" gorm:"primaryKey;size:64"`
+	Name              string    `json:"name"`
+	Description       string    `json:"description"`
+	Organization      string    `json:"organization"` // name of the owning organization, kept for display
+	OrganizationID    uint      `json:"organization_id" gorm:"index"`
+	CreatedBy         uint      `json:"created_by" gorm:"index"`   // user ID of the creator, for the own data scope
+	Department        string    `json:"department" gorm:"size:64"` // the creator's department, for the department data scope
+	Model             string    `json:"model"`
+	Status            string    `json:"status"`
+	Vendors           string    `json:"vendors"`
+	ReportingCurrency string    `json:"reporting_currency" gorm:"size:8;default:'CNY'"` // cost aggregations are converted to this currency
+	Locale            string    `json:"locale" gorm:"size:8;default:'zh'"`              // language of prompts, configs and logs
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+	Progress          int       `json:"progress"`
+	EstimatedCost     float64   `json:"estimated_cost" gorm:"-"`
+	MissingRates      []string  `json:"missing_rates,omitempty" gorm:"-"` // currency pairs without an exchange rate
+	Tasks             []Task    `json:"tasks" gorm:"foreignKey:ProjectID;constraint:OnDelete:CASCADE"`
 }
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Prompt template kinds
+const (
+	PromptKindTaskGeneration    = "task_generation"    // project description to task list
+	PromptKindTaskDecomposition = "task_decomposition" // task to subtasks
+	PromptKindPhase             = "phase"              // one template per phase, Key is the phase name
+	PromptKindRole              = "role"               // one template per role, Key is the role name
+)
+
+// PromptTemplate is one version of a prompt. Versions are immutable; editing a prompt creates a new
+// version and at most one version per kind, key and locale is active. Placeholders are written as {name}.
+type PromptTemplate struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Kind      string    `json:"kind" gorm:"size:32;uniqueIndex:idx_prompt_version"`
+	Key       string    `json:"key" gorm:"column:prompt_key;size:128;uniqueIndex:idx_prompt_version"` // phase or role name, empty for task prompts
+	Locale    string    `json:"locale" gorm:"size:8;default:'zh';uniqueIndex:idx_prompt_version"`
+	Version   int       `json:"version" gorm:"uniqueIndex:idx_prompt_version"`
+	Content   string    `json:"content" gorm:"type:text"`
+	Author    string    `json:"author"`
+	Note      string    `json:"note"` // what changed in this version
+	Active    bool      `json:"active" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
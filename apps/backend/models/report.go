@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VarianceQuery filters the budget-versus-actual report
+type VarianceQuery struct {
+	ProjectID    string
+	Projects     func(*gorm.DB) *gorm.DB // limits the projects, e.g. to the caller's scope; nil means all
+	From         *time.Time
+	To           *time.Time
+	Interval     string // month, quarter, year or all
+	IncludeBills bool
+}
+
+// VarianceRow compares budgeted and actual amounts for one project, task type and period
+// Variance is Actual - Budget, so a positive value is an overrun.
+// VariancePercent is nil when nothing was budgeted.
+type VarianceRow struct {
+	ProjectID       string   `json:"project_id"`
+	ProjectName     string   `json:"project_name"`
+	TaskType        string   `json:"task_type,omitempty"`
+	Period          string   `json:"period,omitempty"`
+	Currency        string   `json:"currency"`
+	Budget          float64  `json:"budget"`
+	Actual          float64  `json:"actual"`
+	Variance        float64  `json:"variance"`
+	VariancePercent *float64 `json:"variance_percent"`
+}
+
+type VarianceReport struct {
+	Interval string        `json:"interval"`
+	From     *time.Time    `json:"from,omitempty"`
+	To       *time.Time    `json:"to,omitempty"`
+	Rows     []VarianceRow `json:"rows"`
+	Projects []VarianceRow `json:"projects"`
+	// MissingRates lists currency pairs without an exchange rate; amounts in them are excluded
+	MissingRates []string  `json:"missing_rates,omitempty"`
+	GeneratedAt  time.Time `json:"generated_at"`
+}
@@ -1,16 +1,21 @@
 package models
 
 type CreateProjectRequest struct {
-	Name         string `json:"name"`
-	Description  string `json:"description"`
-	Organization string `json:"organization"`
-	Model        string `json:"model"`
-	Config       string `json:"config"`
-	Vendors      string `json:"vendors"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	Organization      string `json:"organization"`    // ignored, the name is taken from OrganizationID
+	OrganizationID    uint   `json:"organization_id"` // required unless the caller manages exactly one organization
+	Model             string `json:"model"`
+	Config            string `json:"config"`
+	Vendors           string `json:"vendors"`
+	ReportingCurrency string `json:"reporting_currency"` // defaults to CNY
+	Locale            string `json:"locale"`             // zh or en, defaults to zh
 }
 
 type CreateTaskRequest struct {
 	ProjectID     string  `json:"project_id"`
+	ParentID      string  `json:"parent_id"`
+	ClearParent   bool    `json:"clear_parent"` // on update, moves the task to the top level
 	Name          string  `json:"name"`
 	Description   string  `json:"description"`
 	Type          string  `json:"type"`
@@ -19,4 +24,87 @@ type CreateTaskRequest struct {
 	EstimatedDays int     `json:"estimated_days"`
 	EstimatedCost float64 `json:"estimated_cost"`
 	ExpenseType   string  `json:"expense_type"`
+	Currency      string  `json:"currency"`
+}
+
+type CreateBillRuleRequest struct {
+	ProjectID string `json:"project_id"`
+	Vendor    string `json:"vendor"`
+	TagKey    string `json:"tag_key"`
+	TagValue  string `json:"tag_value"`
+	Field     string `json:"field"`
+	Pattern   string `json:"pattern"`
+	Priority  int    `json:"priority"`
+}
+
+type CreateExchangeRateRequest struct {
+	BaseCurrency  string  `json:"base_currency"`
+	QuoteCurrency string  `json:"quote_currency"`
+	Rate          float64 `json:"rate"`
+	EffectiveDate string  `json:"effective_date"` // YYYY-MM-DD, defaults to today
+}
+
+type ApplyTaskPlanRequest struct {
+	Accept    []string `json:"accept"` // change IDs to apply
+	AcceptAll bool     `json:"accept_all"`
+}
+
+// CompleteTaskRequest records how long a task actually took and what it cost
+type CompleteTaskRequest struct {
+	ActualDays float64  `json:"actual_days"`
+	ActualCost *float64 `json:"actual_cost"` // in the task's currency; omitted when unknown
+}
+
+type CreatePromptTemplateRequest struct {
+	Kind     string `json:"kind"`
+	Key      string `json:"key"`
+	Locale   string `json:"locale"` // defaults to zh
+	Content  string `json:"content"`
+	Author   string `json:"author"`
+	Note     string `json:"note"`
+	Activate bool   `json:"activate"` // make the new version active right away
+}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type CreateUserRequest struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	Role       string `json:"role"` // admin or user, defaults to user
+	Department string `json:"department"`
+}
+
+// SetDepartmentRequest moves a user to another department; empty removes them from their department
+type SetDepartmentRequest struct {
+	Department string `json:"department"`
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+// OrganizationMemberRequest adds a member by user ID or username, or changes a member's role
+type OrganizationMemberRequest struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+	Role     string `json:"role"` // owner, manager or viewer
+}
+
+// CreateAPIKeyRequest creates a key for the caller
+type CreateAPIKeyRequest struct {
+	Name          string `json:"name"`
+	Scope         string `json:"scope"`           // read, project-write or admin
+	ExpiresInDays int    `json:"expires_in_days"` // 0 never expires
 }
@@ -3,24 +3,36 @@ package models
 import "time"
 
 type Task struct {
-	ID            string      `json:"id" gorm:"primaryKey;size:64"`
-	ProjectID     string      `json:"project_id" gorm:"index;size:64"`
-	Name          string      `json:"name"`
-	Description   string      `json:"description"`
-	Type          string      `json:"type"`
-	Status        string      `json:"status"`
-	Priority      int         `json:"priority"`
-	AssignedRole  string      `json:"assigned_role"`
-	CurrentPhase  string      `json:"current_phase"`
-	Progress      int         `json:"progress"`
-	Requirements  string      `json:"requirements"`
-	Language      string      `json:"language"`
-	EstimatedDays int         `json:"estimated_days"`
-	EstimatedCost float64     `json:"estimated_cost"`
-	ExpenseType   string      `json:"expense_type" gorm:"default:'budget'"` // budget: 预算, cost: 成本
-	CreatedAt     time.Time   `json:"created_at"`
-	UpdatedAt     time.Time   `json:"updated_at"`
-	Results       TaskResults `json:"results" gorm:"embedded;embeddedPrefix:results_"`
+	ID               string      `json:"id" gorm:"primaryKey;size:64"`
+	ProjectID        string      `json:"project_id" gorm:"index;size:64"`
+	ParentID         string      `json:"parent_id,omitempty" gorm:"index;size:64"` // empty for top-level tasks
+	Name             string      `json:"name"`
+	Description      string      `json:"description"`
+	Type             string      `json:"type"`
+	Status           string      `json:"status"`
+	Priority         int         `json:"priority"`
+	AssignedRole     string      `json:"assigned_role"`
+	CurrentPhase     string      `json:"current_phase"`
+	Progress         int         `json:"progress"`
+	Requirements     string      `json:"requirements"`
+	Language         string      `json:"language"`
+	EstimatedDays    int         `json:"estimated_days"`
+	EstimatedCost    float64     `json:"estimated_cost"`
+	EstimateModel    string      `json:"estimate_model,omitempty" gorm:"size:128"` // model that produced the estimate
+	CalibratedDays   *float64    `json:"calibrated_days,omitempty"`
+	CalibratedCost   *float64    `json:"calibrated_cost,omitempty"`
+	PromptTemplateID uint        `json:"prompt_template_id,omitempty"` // template version that generated the task, 0 for the built-in prompt
+	PromptVersion    int         `json:"prompt_version,omitempty"`
+	ActualDays       *float64    `json:"actual_days,omitempty"` // recorded on completion
+	ActualCost       *float64    `json:"actual_cost,omitempty"`
+	StartedAt        *time.Time  `json:"started_at,omitempty"` // first execution start
+	CompletedAt      *time.Time  `json:"completed_at,omitempty"`
+	Currency         string      `json:"currency" gorm:"size:8;default:'CNY'"`
+	ExpenseType      string      `json:"expense_type" gorm:"default:'budget'"` // budget: 预算, cost: 成本
+	CreatedAt        time.Time   `json:"created_at"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+	Results          TaskResults `json:"results" gorm:"embedded;embeddedPrefix:results_"`
+	Children         []Task      `json:"children,omitempty" gorm:"-"` // filled in tree responses only
 }
 
 type TaskResults struct {
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// TaskChange is one difference between the current task list and a regenerated proposal
+type TaskChange struct {
+	ID       string   `json:"id"`     // stable within a preview, used to accept the change
+	Action   string   `json:"action"` // added, removed, changed
+	TaskID   string   `json:"task_id,omitempty"`
+	Fields   []string `json:"fields,omitempty"` // changed fields, only for action=changed
+	Existing *Task    `json:"existing,omitempty"`
+	Proposed *Task    `json:"proposed,omitempty"`
+}
+
+// TaskPlanPreview stores a regenerated task proposal until the user applies or discards it
+type TaskPlanPreview struct {
+	ID        string       `json:"id" gorm:"primaryKey;size:64"`
+	ProjectID string       `json:"project_id" gorm:"index;size:64"`
+	Status    string       `json:"status"` // pending, applied, discarded
+	Changes   []TaskChange `json:"changes" gorm:"serializer:json"`
+	Unchanged []string     `json:"unchanged" gorm:"serializer:json"` // IDs of existing tasks kept as they are
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+// TaskPlanResult summarizes an applied preview
+type TaskPlanResult struct {
+	PreviewID string `json:"preview_id"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Changed   int    `json:"changed"`
+	Tasks     []Task `json:"tasks"`
+	// Applied holds the state of every task the plan created, removed or changed, for the audit log
+	Applied []TaskPlanEntry `json:"-"`
+}
+
+// TaskPlanEntry is one task touched by an applied plan; Before is nil for added tasks and After for removed ones
+type TaskPlanEntry struct {
+	Action string
+	Before *Task
+	After  *Task
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// TaskTranscript records one executed phase of a task: the prompts it was given and the template
+// versions they were rendered from, so a bad prompt change can be traced and rolled back
+type TaskTranscript struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	TaskID           string    `json:"task_id" gorm:"index;size:64"`
+	ProjectID        string    `json:"project_id" gorm:"index;size:64"`
+	Phase            string    `json:"phase" gorm:"size:64"`
+	Role             string    `json:"role" gorm:"size:128"`
+	Prompt           string    `json:"prompt" gorm:"type:text"` // rendered phase prompt
+	RolePrompt       string    `json:"role_prompt" gorm:"type:text"`
+	Output           string    `json:"output" gorm:"type:text"`
+	PromptTemplateID uint      `json:"prompt_template_id,omitempty"` // phase template version, 0 when the phase has no active template
+	PromptVersion    int       `json:"prompt_version,omitempty"`
+	RoleTemplateID   uint      `json:"role_template_id,omitempty"` // role template version, 0 when the role has no active template
+	RoleVersion      int       `json:"role_version,omitempty"`
+	CreatedAt        time.Time `json:"created_at"`
+}
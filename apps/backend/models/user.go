@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// User roles
+const (
+	RoleAdmin = "admin" // manages users, models and admin endpoints
+	RoleUser  = "user"
+)
+
+// User is an account that can sign in to the API
+type User struct {
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Username     string         `json:"username" gorm:"size:64;uniqueIndex;not null"`
+	PasswordHash string         `json:"-" gorm:"not null"` // bcrypt
+	Role         string         `json:"role" gorm:"size:16;default:'user'"`
+	Department   string         `json:"department" gorm:"size:64"` // used by the department data scope
+	Active       bool           `json:"active" gorm:"default:true"`
+	TokenVersion int            `json:"-" gorm:"not null;default:0"` // bumped to revoke all refresh tokens of the user
+	LastLoginAt  *time.Time     `json:"last_login_at"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TokenResponse is returned by login and refresh
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"` // always Bearer
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+	User         User   `json:"user"`
+}
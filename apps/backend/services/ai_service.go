@@ -1,136 +1,107 @@
 package services
 
 import (
-	"context"
-	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/openai"
+	"neuro-dev/logging"
 	"neuro-dev/models"
 )
 
-func (s *Service) callLLMAPI(prompt string, model string) []models.Task {
-	// Load configuration for API key (still needed)
-
-	// Get model properties from database using ModelService
-	modelData, err := s.ModelService.GetModelByName(model)
+// callLLMAPI sends req, which holds the initial prompt, and returns the validated tasks.
+// The tasks are stamped with the prompt template version of the request.
+func (s *Service) callLLMAPI(req LLMRequest, model string) ([]models.Task, error) {
+	ctx := logging.With(s.Context(), "project_id", req.ProjectID, "model", model)
+	modelData, err := s.resolveModel(model)
 	if err != nil {
-		log.Printf("Failed to find model '%s' in database: %v", model, err)
-		return s.getFallbackTasks(err.Error())
+		slog.ErrorContext(ctx, "Failed to find model in database", "error", err)
+		return nil, err
 	}
 
-	// Create OpenAI client using model data from database
-	llm, err := openai.New(
-		openai.WithToken(modelData.Token),
-		openai.WithModel(modelData.Name),
-		openai.WithBaseURL(modelData.BaseURL),
-	)
-	if err != nil {
-		log.Printf("Failed to create OpenAI client: %v", err)
-		return s.getFallbackTasks(err.Error())
-	}
+	req.Structured = true
 
-	ctx := context.Background()
-	content := []llms.MessageContent{
-		llms.TextParts(llms.ChatMessageTypeHuman, prompt),
+	// Validation errors are sent back to the model until the reply passes or attempts run out
+	var errs []string
+	for attempt := 0; attempt <= maxTaskRepairAttempts; attempt++ {
+		reply, err := s.LLM.Complete(ctx, modelData, req)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to generate content", "phase", req.Phase, "error", err)
+			return nil, err
+		}
+
+		var generated []generatedTask
+		generated, errs = decodeGeneratedTasks(reply)
+		if len(errs) == 0 {
+			tasks := s.tasksFromGenerated(generated)
+			for i := range tasks {
+				tasks[i].PromptTemplateID = req.PromptTemplateID
+				tasks[i].PromptVersion = req.PromptVersion
+			}
+			return tasks, nil
+		}
+		slog.WarnContext(ctx, "Generated tasks failed validation", "attempt", attempt+1, "attempts", maxTaskRepairAttempts+1, "errors", strings.Join(errs, "; "))
+		req.Messages = append(req.Messages,
+			LLMMessage{Role: llms.ChatMessageTypeAI, Content: reply},
+			LLMMessage{Role: llms.ChatMessageTypeHuman, Content: taskRepairPrompt(req.Locale, errs)},
+		)
 	}
+	return nil, fmt.Errorf("generated tasks failed validation: %s", strings.Join(errs, "; "))
+}
 
-	response, err := llm.GenerateContent(ctx, content, llms.WithTemperature(0.7))
+// resolveModel loads a model with its decrypted token. Offline clients need no provider settings,
+// so they get a model with just the name and no database record is required.
+func (s *Service) resolveModel(name string) (*models.Model, error) {
+	if isOffline(s.LLM) {
+		return &models.Model{Name: name}, nil
+	}
+	m, err := s.ModelService.GetModelByName(name)
 	if err != nil {
-		log.Printf("Failed to generate content: %v", err)
-		return s.getFallbackTasks(err.Error())
+		return nil, err
 	}
-
-	// Parse the response and convert to tasks
-	tasks := s.parseTasksFromResponse(response.Choices[0].Content)
-
-	return tasks
+	return s.decryptModel(m)
 }
 
 // getFallbackTasks returns default tasks when LLM API fails
-func (s *Service) getFallbackTasks(desc string) []models.Task {
+func (s *Service) getFallbackTasks(desc, locale string) []models.Task {
 	baseTime := time.Now()
 	return []models.Task{
 		{
 			ID:           uuid.NewString(),
-			Name:         "生成任务失败",
+			Name:         T(locale, "task.fallback_name"),
 			Description:  desc,
 			Type:         "bug",
 			Status:       "pending",
 			Priority:     2,
 			AssignedRole: "architect",
-			Requirements: "架构设计、数据库设计、接口设计",
+			Requirements: T(locale, "task.fallback_requirement"),
 			CreatedAt:    baseTime,
 			UpdatedAt:    baseTime,
 		},
 	}
 }
 
-// parseTasksFromResponse parses LLM response to extract tasks
-func (s *Service) parseTasksFromResponse(response string) []models.Task {
-	// Define a struct to match the JSON format from LLM response
-	type TaskFromLLM struct {
-		Name          string  `json:"name"`
-		Description   string  `json:"description"`
-		Type          string  `json:"type"`
-		Priority      int     `json:"priority"`
-		AssignedRole  string  `json:"assigned_role"`
-		Requirements  string  `json:"requirements"`
-		EstimatedDays int     `json:"estimated_days"`
-		EstimatedCost float64 `json:"estimated_cost"`
-	}
-
-	tasks := []models.Task{}
-
-	// Try to extract JSON from the response (LLM might return text with JSON embedded)
-	jsonStart := strings.Index(response, "[")
-	jsonEnd := strings.LastIndex(response, "]")
-
-	if jsonStart == -1 || jsonEnd == -1 || jsonStart >= jsonEnd {
-		err := fmt.Errorf("No valid JSON array found in response", response)
-		log.Printf("No valid JSON array found in response")
-		return s.getFallbackTasks(err.Error())
-	}
-
-	jsonStr := response[jsonStart : jsonEnd+1]
-
-	// Parse JSON array
-	var llmTasks []TaskFromLLM
-	err := json.Unmarshal([]byte(jsonStr), &llmTasks)
-	if err != nil {
-		log.Printf("JSON: %v", jsonStr)
-		log.Printf("Failed to parse JSON from LLM response: %v", err)
-		return s.getFallbackTasks(err.Error())
-	}
-
-	// Convert LLM tasks to models.Task
-	for _, llmTask := range llmTasks {
-		task := models.Task{
+// tasksFromGenerated converts validated model output to models.Task
+func (s *Service) tasksFromGenerated(generated []generatedTask) []models.Task {
+	tasks := make([]models.Task, 0, len(generated))
+	for _, g := range generated {
+		tasks = append(tasks, models.Task{
 			ID:            uuid.NewString(),
-			Name:          llmTask.Name,
-			Description:   llmTask.Description,
-			Type:          llmTask.Type,
+			Name:          g.Name,
+			Description:   g.Description,
+			Type:          g.Type,
 			Status:        "pending", // Default status
-			Priority:      llmTask.Priority,
-			AssignedRole:  llmTask.AssignedRole,
-			Requirements:  llmTask.Requirements,
-			EstimatedDays: llmTask.EstimatedDays,
-			EstimatedCost: llmTask.EstimatedCost,
+			Priority:      g.Priority,
+			AssignedRole:  g.AssignedRole,
+			Requirements:  g.Requirements,
+			EstimatedDays: g.EstimatedDays,
+			EstimatedCost: g.EstimatedCost,
 			CreatedAt:     time.Now(),
 			UpdatedAt:     time.Now(),
-		}
-		tasks = append(tasks, task)
+		})
 	}
-
-	// If no tasks were parsed successfully, return at least one default task
-	if len(tasks) == 0 {
-		return s.getFallbackTasks(err.Error())
-	}
-
 	return tasks
 }
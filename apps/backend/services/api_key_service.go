@@ -0,0 +1,159 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"neuro-dev/models"
+)
+
+// APIKeyPrefix starts every API key so it can be told apart from a JWT in the Authorization header
+const APIKeyPrefix = "nk_"
+
+const (
+	tokenTypeAPIKey = "api_key"
+	// apiKeyTouchInterval limits how often last_used_at is written for a busy key
+	apiKeyTouchInterval = time.Minute
+)
+
+// IsAPIKeyScope reports whether scope is read, project-write or admin
+func IsAPIKeyScope(scope string) bool {
+	switch scope {
+	case models.APIKeyScopeRead, models.APIKeyScopeProjectWrite, models.APIKeyScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// IsAPIKey reports whether a bearer token is an API key rather than a JWT
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey generates a key owned by a user. Admin keys need an admin owner.
+func (s *Service) CreateAPIKey(userID uint, req models.CreateAPIKeyRequest) (*models.APIKeyResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	if !IsAPIKeyScope(req.Scope) {
+		return nil, fmt.Errorf("invalid scope %q, expected read, project-write or admin", req.Scope)
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, errors.New("expires_in_days must not be negative")
+	}
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("load user failed: %w", err)
+	}
+	if req.Scope == models.APIKeyScopeAdmin && user.Role != models.RoleAdmin {
+		return nil, ErrForbidden
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("generate api key failed: %w", err)
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	apiKey := models.APIKey{
+		Name:   name,
+		Prefix: key[:len(APIKeyPrefix)+6],
+		Hash:   hashAPIKey(key),
+		Scope:  req.Scope,
+		UserID: userID,
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		apiKey.ExpiresAt = &expires
+	}
+	if err := s.DB.Create(&apiKey).Error; err != nil {
+		return nil, fmt.Errorf("create api key failed: %w", err)
+	}
+	return &models.APIKeyResponse{APIKey: apiKey, Key: key}, nil
+}
+
+// ListAPIKeys returns the keys of a user, or of everyone when userID is 0
+func (s *Service) ListAPIKeys(userID uint) ([]models.APIKey, error) {
+	query := s.DB.Order("id desc")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	var keys []models.APIKey
+	if err := query.Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey disables a key for good. Keys of other users can only be revoked by admins.
+func (s *Service) RevokeAPIKey(id, userID uint, admin bool) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := s.DB.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	if key.UserID != userID && !admin {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		if err := s.DB.Model(&key).Update("revoked_at", now).Error; err != nil {
+			return nil, err
+		}
+		key.RevokedAt = &now
+	}
+	return &key, nil
+}
+
+// ParseAPIKey checks an API key and returns claims for its owner. The role is only admin for
+// admin keys of admins, so a narrower key never gains the owner's admin rights.
+func (s *Service) ParseAPIKey(key string) (*Claims, error) {
+	var apiKey models.APIKey
+	if err := s.DB.Where("hash = ?", hashAPIKey(key)).First(&apiKey).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if apiKey.RevokedAt != nil || apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	var user models.User
+	if err := s.DB.First(&user, apiKey.UserID).Error; err != nil || !user.Active {
+		return nil, ErrInvalidToken
+	}
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		if err := s.DB.Model(&apiKey).Update("last_used_at", now).Error; err != nil {
+			return nil, fmt.Errorf("update api key failed: %w", err)
+		}
+	}
+
+	role := models.RoleUser
+	if apiKey.Scope == models.APIKeyScopeAdmin && user.Role == models.RoleAdmin {
+		role = models.RoleAdmin
+	}
+	return &Claims{
+		Username: user.Username,
+		Role:     role,
+		Type:     tokenTypeAPIKey,
+		KeyID:    apiKey.ID,
+		KeyScope: apiKey.Scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: strconv.FormatUint(uint64(user.ID), 10),
+		},
+	}, nil
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"neuro-dev/models"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// auditIgnoredFields change on every write or are derived, so they are left out of diffs
+var auditIgnoredFields = map[string]bool{
+	"updated_at":    true,
+	"tasks":         true,
+	"children":      true,
+	"token_preview": true,
+}
+
+// RecordAudit stores an audit entry with the field changes between before and after.
+// Pass nil before for creations and nil after for deletions. Updates that change nothing are not recorded.
+func (s *Service) RecordAudit(entry models.AuditLog, before, after interface{}) error {
+	changes, err := AuditDiff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 && entry.Action == models.AuditUpdate {
+		return nil
+	}
+	entry.ID = 0
+	entry.Changes = changes
+	if err := s.DB.Create(&entry).Error; err != nil {
+		return fmt.Errorf("save audit log failed: %w", err)
+	}
+	return nil
+}
+
+// AuditDiff compares the JSON fields of two values. Model tokens are not serialised,
+// so a replaced token is reported as a redacted change.
+func AuditDiff(before, after interface{}) (map[string]models.AuditChange, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+	changes := make(map[string]models.AuditChange)
+	for key, value := range b {
+		if !auditIgnoredFields[key] && !reflect.DeepEqual(value, a[key]) {
+			changes[key] = models.AuditChange{Before: value, After: a[key]}
+		}
+	}
+	for key, value := range a {
+		if _, seen := b[key]; !seen && !auditIgnoredFields[key] {
+			changes[key] = models.AuditChange{After: value}
+		}
+	}
+	if bm, ok := before.(models.Model); ok {
+		if am, ok := after.(models.Model); ok && bm.Token != am.Token {
+			changes["token"] = models.AuditChange{Before: "[redacted]", After: "[redacted]"}
+		}
+	}
+	return changes, nil
+}
+
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if v == nil {
+		return fields, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode audit value failed: %w", err)
+	}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("decode audit value failed: %w", err)
+	}
+	return fields, nil
+}
+
+// ListAuditLogs returns matching audit entries, newest first, and the total number of matches.
+// Non-admins only see entries of projects in their scope.
+func (s *Service) ListAuditLogs(sc *Scope, q models.AuditQuery) ([]models.AuditLog, int64, error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultAuditLimit
+	}
+	if q.Limit > maxAuditLimit {
+		return nil, 0, fmt.Errorf("limit must not exceed %d", maxAuditLimit)
+	}
+	if q.Offset < 0 {
+		return nil, 0, errors.New("offset must not be negative")
+	}
+
+	query := s.DB.Model(&models.AuditLog{})
+	if !sc.Admin {
+		query = query.Where("project_id IN (?)", sc.ProjectIDs(s.DB.Session(&gorm.Session{NewDB: true})))
+	}
+	filters := map[string]string{
+		"entity_type": q.EntityType,
+		"entity_id":   q.EntityID,
+		"project_id":  q.ProjectID,
+		"actor":       q.Actor,
+		"action":      q.Action,
+		"request_id":  q.RequestID,
+	}
+	for column, value := range filters {
+		if value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if q.From != nil {
+		query = query.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		query = query.Where("created_at < ?", *q.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("count audit logs failed: %w", err)
+	}
+	logs := []models.AuditLog{}
+	if err := query.Order("id desc").Limit(q.Limit).Offset(q.Offset).Find(&logs).Error; err != nil {
+		return nil, 0, fmt.Errorf("load audit logs failed: %w", err)
+	}
+	return logs, total, nil
+}
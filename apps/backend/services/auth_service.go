@@ -0,0 +1,288 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"neuro-dev/models"
+)
+
+var (
+	// ErrInvalidCredentials is returned for an unknown user, a wrong password or a disabled account
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrInvalidToken is returned for expired, malformed or wrongly signed tokens
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// Token types; a refresh token cannot be used to call the API and vice versa
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+const (
+	defaultAccessTokenTTL  = time.Hour
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+	minPasswordLength      = 8
+)
+
+// dummyPasswordHash is compared against when a user does not exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("neuro-dev-dummy-password"), bcrypt.DefaultCost)
+
+// Claims are the JWT claims of access and refresh tokens; the subject is the user ID.
+// Requests authenticated with an API key get claims of type api_key that are never signed.
+type Claims struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Type     string `json:"typ"`
+	Version  int    `json:"ver,omitempty"` // models.User.TokenVersion when the token was issued
+	KeyID    uint   `json:"-"`             // API key ID, 0 for user sessions
+	KeyScope string `json:"-"`             // API key scope, empty for user sessions
+	jwt.RegisteredClaims
+}
+
+// UserID returns the numeric user ID of the subject
+func (c *Claims) UserID() uint {
+	id, _ := strconv.ParseUint(c.Subject, 10, 64)
+	return uint(id)
+}
+
+// IsAdmin reports whether the token belongs to an administrator
+func (c *Claims) IsAdmin() bool {
+	return c.Role == models.RoleAdmin
+}
+
+// IsAPIKey reports whether the request was authenticated with an API key
+func (c *Claims) IsAPIKey() bool {
+	return c.Type == tokenTypeAPIKey
+}
+
+func (s *Service) accessTokenTTL() time.Duration {
+	if s.JWT.Timeout > 0 {
+		return time.Duration(s.JWT.Timeout) * time.Second
+	}
+	return defaultAccessTokenTTL
+}
+
+func (s *Service) refreshTokenTTL() time.Duration {
+	if s.JWT.RefreshTimeout > 0 {
+		return time.Duration(s.JWT.RefreshTimeout) * time.Second
+	}
+	return defaultRefreshTokenTTL
+}
+
+// Login checks a username and password and issues a token pair
+func (s *Service) Login(username, password string) (*models.TokenResponse, error) {
+	var user models.User
+	if err := s.DB.Where("username = ?", strings.TrimSpace(username)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Compare anyway so unknown users take as long as wrong passwords
+			_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if !user.Active || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	now := time.Now()
+	if err := s.DB.Model(&user).Update("last_login_at", now).Error; err != nil {
+		return nil, err
+	}
+	user.LastLoginAt = &now
+	return s.issueTokens(&user)
+}
+
+// Refresh exchanges a valid refresh token for a new token pair. The user is reloaded so that
+// disabled accounts, role changes and revoked tokens take effect.
+func (s *Service) Refresh(refreshToken string) (*models.TokenResponse, error) {
+	claims, err := s.parseToken(refreshToken, tokenTypeRefresh)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := s.DB.First(&user, claims.UserID()).Error; err != nil || !user.Active || claims.Version != user.TokenVersion {
+		return nil, ErrInvalidToken
+	}
+	return s.issueTokens(&user)
+}
+
+// ParseAccessToken validates an access token and returns its claims
+func (s *Service) ParseAccessToken(token string) (*Claims, error) {
+	return s.parseToken(token, tokenTypeAccess)
+}
+
+func (s *Service) parseToken(token, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(s.JWT.Secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || claims.Type != tokenType {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+func (s *Service) issueTokens(user *models.User) (*models.TokenResponse, error) {
+	access, err := s.signToken(user, tokenTypeAccess, s.accessTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := s.signToken(user, tokenTypeRefresh, s.refreshTokenTTL())
+	if err != nil {
+		return nil, err
+	}
+	return &models.TokenResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTokenTTL().Seconds()),
+		User:         *user,
+	}, nil
+}
+
+func (s *Service) signToken(user *models.User, tokenType string, ttl time.Duration) (string, error) {
+	if s.JWT.Secret == "" {
+		return "", errors.New("jwt secret is not configured")
+	}
+	now := time.Now()
+	claims := Claims{
+		Username: user.Username,
+		Role:     user.Role,
+		Type:     tokenType,
+		Version:  user.TokenVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.JWT.Secret))
+}
+
+// CreateUser validates the request and stores a user with a bcrypt hashed password
+func (s *Service) CreateUser(req models.CreateUserRequest) (*models.User, error) {
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	if len(req.Password) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	role := req.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	if role != models.RoleAdmin && role != models.RoleUser {
+		return nil, fmt.Errorf("invalid role %q, expected admin or user", req.Role)
+	}
+	var count int64
+	if err := s.DB.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("user %s already exists", username)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("hash password failed: %w", err)
+	}
+	user := &models.User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+		Department:   strings.TrimSpace(req.Department),
+		Active:       true,
+	}
+	if err := s.DB.Create(user).Error; err != nil {
+		return nil, fmt.Errorf("create user failed: %w", err)
+	}
+	return user, nil
+}
+
+// SetUserDepartment changes the department a user belongs to. Projects keep the department they were
+// created in; the department data scope of the user follows the change from their next request.
+func (s *Service) SetUserDepartment(userID uint, department string) (*models.User, error) {
+	department = strings.TrimSpace(department)
+	if len(department) > 64 {
+		return nil, errors.New("department must be at most 64 characters")
+	}
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(&user).Update("department", department).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ChangePassword replaces a user's password after checking the current one. All refresh tokens issued
+// before are revoked; the returned token pair keeps the calling session signed in.
+func (s *Service) ChangePassword(userID uint, oldPassword, newPassword string) (*models.TokenResponse, error) {
+	var user models.User
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(oldPassword)) != nil {
+		return nil, ErrInvalidCredentials
+	}
+	if len(newPassword) < minPasswordLength {
+		return nil, fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("hash password failed: %w", err)
+	}
+	if err := s.DB.Model(&user).Updates(map[string]interface{}{
+		"password_hash": string(hash),
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error; err != nil {
+		return nil, err
+	}
+	if err := s.DB.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return s.issueTokens(&user)
+}
+
+// EnsureAdmin creates the bootstrap admin account when there are no users yet.
+// Without a configured password a random one is generated and logged once.
+func (s *Service) EnsureAdmin(username, password string) error {
+	var count int64
+	if err := s.DB.Model(&models.User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if username == "" {
+		username = "admin"
+	}
+	generated := password == ""
+	if generated {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+	}
+	if _, err := s.CreateUser(models.CreateUserRequest{Username: username, Password: password, Role: models.RoleAdmin}); err != nil {
+		return fmt.Errorf("create admin user failed: %w", err)
+	}
+	if generated {
+		slog.Warn("Created admin user with generated password, change it after signing in", "username", username, "password", password)
+	} else {
+		slog.Info("Created admin user from settings", "username", username)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"neuro-dev/config"
+	"neuro-dev/models"
+)
+
+func TestChangePasswordRevokesRefreshTokens(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	s.JWT = config.JWT{Secret: "test-secret-of-at-least-32-bytes!"}
+	user, err := s.CreateUser(models.CreateUserRequest{Username: "alice", Password: "password1"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	first, err := s.Login("alice", "password1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	second, err := s.Login("alice", "password1")
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if _, err := s.Refresh(first.RefreshToken); err != nil {
+		t.Fatalf("Refresh before password change: %v", err)
+	}
+
+	changed, err := s.ChangePassword(user.ID, "password1", "password2")
+	if err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	for name, token := range map[string]string{"first": first.RefreshToken, "second": second.RefreshToken} {
+		if _, err := s.Refresh(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s session refreshed after password change: %v", name, err)
+		}
+	}
+	if _, err := s.Refresh(changed.RefreshToken); err != nil {
+		t.Errorf("Refresh with the new pair: %v", err)
+	}
+	if _, err := s.Login("alice", "password1"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("old password still accepted: %v", err)
+	}
+}
@@ -0,0 +1,464 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"neuro-dev/models"
+)
+
+// billColumns lists the candidate CSV headers for each normalized bill field of a vendor export.
+// Headers are matched case-insensitively; the first header present in the file wins.
+type billColumns struct {
+	ServiceType     []string
+	Resource        []string
+	PeriodStart     []string
+	PeriodEnd       []string
+	Period          []string // billing cycle such as 2024-05, used when no start/end columns exist
+	Amount          []string
+	Currency        []string
+	Tags            []string
+	TagPrefix       string // one column per tag, e.g. AWS CUR resourceTags/user:<key>
+	DefaultCurrency string
+}
+
+var billVendorColumns = map[string]billColumns{
+	"aliyun": {
+		ServiceType:     []string{"产品", "产品名称", "Product", "ProductName", "Product Name"},
+		Resource:        []string{"实例ID", "实例 ID", "资源ID", "InstanceID", "Instance ID", "ResourceId"},
+		PeriodStart:     []string{"消费时间", "账单开始时间", "UsageStartTime", "Usage Start Time"},
+		PeriodEnd:       []string{"账单结束时间", "UsageEndTime", "Usage End Time"},
+		Period:          []string{"账期", "账单月份", "BillingCycle", "Billing Cycle"},
+		Amount:          []string{"应付金额", "应付金额（含税）", "PretaxAmount", "Pretax Amount", "PaymentAmount"},
+		Currency:        []string{"币种", "Currency"},
+		Tags:            []string{"实例标签", "标签", "Tag", "Tags"},
+		DefaultCurrency: "CNY",
+	},
+	"huaweicloud": {
+		ServiceType:     []string{"云服务类型", "产品类型", "Service Type", "CloudServiceType", "Cloud Service Type"},
+		Resource:        []string{"资源ID", "资源 ID", "Resource ID", "ResourceId"},
+		PeriodStart:     []string{"开始时间", "Start Time", "EffectiveTime"},
+		PeriodEnd:       []string{"结束时间", "End Time", "ExpireTime"},
+		Period:          []string{"账期", "Bill Cycle", "BillCycle"},
+		Amount:          []string{"应付金额", "消费金额", "Amount Due", "Amount", "OfficialAmount"},
+		Currency:        []string{"币种", "Currency"},
+		Tags:            []string{"资源标签", "标签", "Resource Tag", "Tag"},
+		DefaultCurrency: "CNY",
+	},
+	"aws": {
+		ServiceType:     []string{"lineItem/ProductCode", "product/ProductName", "ProductCode", "Service"},
+		Resource:        []string{"lineItem/ResourceId", "ResourceId", "Resource ID"},
+		PeriodStart:     []string{"lineItem/UsageStartDate", "UsageStartDate", "Usage Start Date"},
+		PeriodEnd:       []string{"lineItem/UsageEndDate", "UsageEndDate", "Usage End Date"},
+		Period:          []string{"bill/BillingPeriodStartDate", "BillingPeriod"},
+		Amount:          []string{"lineItem/UnblendedCost", "UnblendedCost", "Cost", "Amount"},
+		Currency:        []string{"lineItem/CurrencyCode", "CurrencyCode", "Currency"},
+		Tags:            []string{"Tags"},
+		TagPrefix:       "resourceTags/user:",
+		DefaultCurrency: "USD",
+	},
+	"gcp": {
+		ServiceType:     []string{"Service description", "service.description", "Service"},
+		Resource:        []string{"Resource name", "resource.name", "SKU description", "sku.description"},
+		PeriodStart:     []string{"Usage start date", "usage_start_time"},
+		PeriodEnd:       []string{"Usage end date", "usage_end_time"},
+		Period:          []string{"Invoice month", "invoice.month"},
+		Amount:          []string{"Cost ($)", "Unrounded Cost ($)", "Cost", "cost"},
+		Currency:        []string{"Currency", "currency"},
+		Tags:            []string{"Labels", "labels"},
+		TagPrefix:       "label:",
+		DefaultCurrency: "USD",
+	},
+}
+
+// billProjectTagKeys are tag keys whose value is matched against project IDs and names
+// when no explicit BillRule applies.
+var billProjectTagKeys = []string{"project_id", "project", "neuro-project"}
+
+var billTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+}
+
+var billMonthLayouts = []string{"2006-01", "2006/01", "200601"}
+
+// NormalizeVendor maps vendor labels used by the frontend and exports to vendor codes
+func NormalizeVendor(vendor string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(vendor)) {
+	case "aliyun", "alibaba", "alibabacloud", "alibaba cloud", "阿里云":
+		return "aliyun", nil
+	case "huaweicloud", "huawei", "huawei cloud", "华为云":
+		return "huaweicloud", nil
+	case "aws", "amazon", "amazon web services", "亚马逊云":
+		return "aws", nil
+	case "gcp", "google", "googlecloud", "google cloud", "谷歌云":
+		return "gcp", nil
+	}
+	return "", fmt.Errorf("unsupported vendor: %s", vendor)
+}
+
+// ImportBills parses a vendor CSV export, stores new lines and maps them to projects.
+// When projectID is set every imported line is assigned to it instead of being mapped by rules.
+func (s *Service) ImportBills(vendor string, r io.Reader, projectID string) (*models.BillImportResult, error) {
+	code, err := NormalizeVendor(vendor)
+	if err != nil {
+		return nil, err
+	}
+	cols := billVendorColumns[code]
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read csv header failed: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		h = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		header[i] = h
+		index[strings.ToLower(h)] = i
+	}
+	amountCol := lookupColumn(index, cols.Amount)
+	if amountCol < 0 {
+		return nil, fmt.Errorf("csv is not a %s bill export: no amount column found", code)
+	}
+
+	var rules []models.BillRule
+	if projectID == "" {
+		if rules, err = s.loadBillRules(); err != nil {
+			return nil, err
+		}
+	}
+	projects, err := s.projectLookup()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.BillImportResult{ImportID: uuid.NewString(), Vendor: code}
+	seen := map[string]int{}
+	var bills []models.Bill
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read csv line %d failed: %w", line, err)
+		}
+		result.TotalRows++
+
+		bill, tags, err := normalizeBillRecord(code, cols, header, index, record)
+		if err != nil {
+			result.Skipped++
+			if len(result.Errors) < 20 {
+				result.Errors = append(result.Errors, fmt.Sprintf("line %d: %v", line, err))
+			}
+			continue
+		}
+		key := bill.DedupeKey()
+		seen[key]++
+		bill.Fingerprint = models.BillFingerprint(key, seen[key])
+		bill.ID = uuid.NewString()
+		bill.ImportID = result.ImportID
+		if projectID != "" {
+			bill.ProjectID = projectID
+		} else {
+			bill.ProjectID = matchBillProject(bill, tags, rules, projects)
+		}
+		bills = append(bills, *bill)
+	}
+
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range bills {
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&bills[i])
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				result.Duplicates++
+				continue
+			}
+			result.Imported++
+			if bills[i].ProjectID != "" {
+				result.Mapped++
+			} else {
+				result.Unmapped++
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("save bills failed: %w", err)
+	}
+
+	slog.InfoContext(s.Context(), "Imported bills", "import_id", result.ImportID, "vendor", code, "rows", result.TotalRows,
+		"imported", result.Imported, "duplicates", result.Duplicates, "skipped", result.Skipped)
+	return result, nil
+}
+
+// RemapBills re-applies mapping rules to bills that have no project yet and returns how many were mapped
+func (s *Service) RemapBills() (int, error) {
+	rules, err := s.loadBillRules()
+	if err != nil {
+		return 0, err
+	}
+	projects, err := s.projectLookup()
+	if err != nil {
+		return 0, err
+	}
+	var bills []models.Bill
+	if err := s.DB.Where("project_id = ? OR project_id IS NULL", "").Find(&bills).Error; err != nil {
+		return 0, err
+	}
+	mapped := 0
+	for i := range bills {
+		b := &bills[i]
+		projectID := matchBillProject(b, parseBillTags(b.Tags), rules, projects)
+		if projectID == "" {
+			continue
+		}
+		if err := s.DB.Model(&models.Bill{}).Where("id = ?", b.ID).Updates(map[string]interface{}{"project_id": projectID, "updated_at": time.Now()}).Error; err != nil {
+			return mapped, err
+		}
+		mapped++
+	}
+	return mapped, nil
+}
+
+func (s *Service) loadBillRules() ([]models.BillRule, error) {
+	var rules []models.BillRule
+	if err := s.DB.Order("priority asc, id asc").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("load bill rules failed: %w", err)
+	}
+	return rules, nil
+}
+
+// projectLookup indexes project IDs and lower-cased names to project IDs
+func (s *Service) projectLookup() (map[string]string, error) {
+	var projects []models.Project
+	if err := s.DB.Select("id", "name").Find(&projects).Error; err != nil {
+		return nil, fmt.Errorf("load projects failed: %w", err)
+	}
+	lookup := make(map[string]string, len(projects)*2)
+	for _, p := range projects {
+		lookup[strings.ToLower(p.Name)] = p.ID
+	}
+	// IDs win over names when they collide
+	for _, p := range projects {
+		lookup[strings.ToLower(p.ID)] = p.ID
+	}
+	return lookup, nil
+}
+
+func matchBillProject(b *models.Bill, tags map[string]string, rules []models.BillRule, projects map[string]string) string {
+	for _, rule := range rules {
+		if rule.Vendor != "" && rule.Vendor != b.Vendor {
+			continue
+		}
+		if rule.TagKey != "" {
+			if v, ok := tags[strings.ToLower(rule.TagKey)]; ok && (rule.TagValue == "" || strings.EqualFold(v, rule.TagValue)) {
+				return rule.ProjectID
+			}
+			continue
+		}
+		if rule.Pattern == "" {
+			continue
+		}
+		var value string
+		switch rule.Field {
+		case "resource":
+			value = b.Resource
+		default:
+			value = b.ServiceType
+		}
+		if strings.Contains(strings.ToLower(value), strings.ToLower(rule.Pattern)) {
+			return rule.ProjectID
+		}
+	}
+	for _, key := range billProjectTagKeys {
+		if v, ok := tags[key]; ok {
+			if id, ok := projects[strings.ToLower(v)]; ok {
+				return id
+			}
+		}
+	}
+	return ""
+}
+
+func normalizeBillRecord(vendor string, cols billColumns, header []string, index map[string]int, record []string) (*models.Bill, map[string]string, error) {
+	get := func(candidates []string) string {
+		if i := lookupColumn(index, candidates); i >= 0 && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	amountStr := get(cols.Amount)
+	if amountStr == "" {
+		return nil, nil, errors.New("empty amount")
+	}
+	amount, err := parseBillAmount(amountStr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	start, end, err := parseBillPeriod(get(cols.PeriodStart), get(cols.PeriodEnd), get(cols.Period))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tags := parseBillTags(get(cols.Tags))
+	if cols.TagPrefix != "" {
+		for i, h := range header {
+			if i >= len(record) || !strings.HasPrefix(strings.ToLower(h), strings.ToLower(cols.TagPrefix)) {
+				continue
+			}
+			if v := strings.TrimSpace(record[i]); v != "" {
+				tags[strings.ToLower(h[len(cols.TagPrefix):])] = v
+			}
+		}
+	}
+
+	currency := strings.ToUpper(get(cols.Currency))
+	if currency == "" {
+		currency = cols.DefaultCurrency
+	}
+
+	bill := &models.Bill{
+		Vendor:      vendor,
+		ServiceType: get(cols.ServiceType),
+		Resource:    get(cols.Resource),
+		PeriodStart: start,
+		PeriodEnd:   end,
+		Amount:      amount,
+		Currency:    currency,
+		Tags:        formatBillTags(tags),
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+	if bill.ServiceType == "" && bill.Resource == "" {
+		return nil, nil, errors.New("missing service type and resource")
+	}
+	return bill, tags, nil
+}
+
+func lookupColumn(index map[string]int, candidates []string) int {
+	for _, c := range candidates {
+		if i, ok := index[strings.ToLower(c)]; ok {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseBillAmount(s string) (float64, error) {
+	negative := strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")")
+	cleaned := strings.NewReplacer("(", "", ")", "", ",", "", "¥", "", "￥", "", "$", "", "USD", "", "CNY", "", " ", "").Replace(s)
+	v, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q", s)
+	}
+	if negative {
+		v = -v
+	}
+	return v, nil
+}
+
+// parseBillPeriod prefers explicit start/end columns and falls back to a monthly billing cycle
+func parseBillPeriod(start, end, cycle string) (time.Time, time.Time, error) {
+	if start != "" {
+		st, err := parseBillTime(start)
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		et := st
+		if end != "" {
+			if et, err = parseBillTime(end); err != nil {
+				return time.Time{}, time.Time{}, err
+			}
+		}
+		return st, et, nil
+	}
+	if cycle != "" {
+		for _, layout := range billMonthLayouts {
+			if t, err := time.Parse(layout, cycle); err == nil {
+				return t, t.AddDate(0, 1, 0), nil
+			}
+		}
+		if t, err := parseBillTime(cycle); err == nil {
+			return t, t.AddDate(0, 1, 0), nil
+		}
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid billing cycle %q", cycle)
+	}
+	return time.Time{}, time.Time{}, errors.New("missing billing period")
+}
+
+func parseBillTime(s string) (time.Time, error) {
+	for _, layout := range billTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// parseBillTags accepts "k=v;k=v", "k:v,k:v" and the Alibaba Cloud "key:k value:v" formats
+func parseBillTags(s string) map[string]string {
+	tags := map[string]string{}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return tags
+	}
+	sep := ";"
+	if !strings.Contains(s, ";") {
+		sep = ","
+	}
+	for _, item := range strings.Split(s, sep) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.HasPrefix(item, "key:") && strings.Contains(item, " value:") {
+			kv := strings.SplitN(strings.TrimPrefix(item, "key:"), " value:", 2)
+			tags[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+			continue
+		}
+		i := strings.Index(item, "=")
+		if i < 0 {
+			i = strings.Index(item, ":")
+		}
+		if i <= 0 {
+			continue
+		}
+		tags[strings.ToLower(strings.TrimSpace(item[:i]))] = strings.TrimSpace(item[i+1:])
+	}
+	return tags
+}
+
+func formatBillTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, ";")
+}
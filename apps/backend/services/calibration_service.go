@@ -0,0 +1,360 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"neuro-dev/models"
+)
+
+// ErrTaskHasSubtasks is returned when recording actuals on a task whose effort is tracked on its subtasks
+var ErrTaskHasSubtasks = errors.New("task has subtasks")
+
+// minCalibrationSamples is how many completed tasks a scope needs before its factor is used
+const minCalibrationSamples = 3
+
+// Calibration scopes, from most to least specific
+const (
+	calibrationScopeTypeModel = "type_model"
+	calibrationScopeType      = "type"
+	calibrationScopeModel     = "model"
+	calibrationScopeGlobal    = "global"
+)
+
+type calibrationKey struct {
+	scope    string
+	taskType string
+	model    string
+}
+
+type calibrationStats struct {
+	samples       int
+	estimatedDays float64
+	actualDays    float64
+	costSamples   int
+	estimatedCost float64
+	actualCost    float64
+}
+
+// Calibration holds actual/estimated ratios learnt from completed tasks
+type Calibration struct {
+	stats map[calibrationKey]*calibrationStats
+}
+
+// completedEstimates returns completed leaf tasks with recorded actual days and a positive estimate.
+// Cost entries and parents (whose estimates are rolled up from subtasks) are not estimates of their own.
+func (s *Service) completedEstimates(projectID string) ([]models.Task, error) {
+	query := s.DB.Where("status = ? AND actual_days > 0 AND estimated_days > 0 AND expense_type <> ?", "completed", "cost")
+	if projectID != "" {
+		query = query.Where("project_id = ?", projectID)
+	}
+	var tasks []models.Task
+	if err := query.Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	var parentIDs []string
+	if err := s.DB.Model(&models.Task{}).Where("parent_id <> ?", "").Distinct().Pluck("parent_id", &parentIDs).Error; err != nil {
+		return nil, err
+	}
+	isParent := make(map[string]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		isParent[id] = true
+	}
+	leaves := tasks[:0]
+	for _, t := range tasks {
+		if !isParent[t.ID] {
+			leaves = append(leaves, t)
+		}
+	}
+	return leaves, nil
+}
+
+// LoadCalibration computes bias factors per task type, per model and per type and model from all completed tasks
+func (s *Service) LoadCalibration() (*Calibration, error) {
+	tasks, err := s.completedEstimates("")
+	if err != nil {
+		return nil, fmt.Errorf("load estimation history failed: %w", err)
+	}
+	rates, err := s.LoadRateTable()
+	if err != nil {
+		return nil, err
+	}
+	return newCalibration(tasks, rates), nil
+}
+
+// newCalibration sums estimates and actuals per scope. Costs are converted to models.DefaultCurrency
+// at the task's creation date first, so tasks in large currency units do not dominate the cost factor;
+// costs without a rate are left out.
+func newCalibration(tasks []models.Task, rates *RateTable) *Calibration {
+	c := &Calibration{stats: make(map[calibrationKey]*calibrationStats)}
+	for _, t := range tasks {
+		for _, key := range calibrationKeys(t.Type, t.EstimateModel) {
+			st := c.stats[key]
+			if st == nil {
+				st = &calibrationStats{}
+				c.stats[key] = st
+			}
+			st.samples++
+			st.estimatedDays += float64(t.EstimatedDays)
+			st.actualDays += *t.ActualDays
+			if t.ActualCost != nil && t.EstimatedCost > 0 {
+				estimated, ok := rates.Convert(t.EstimatedCost, t.Currency, models.DefaultCurrency, t.CreatedAt)
+				if !ok {
+					continue
+				}
+				actual, _ := rates.Convert(*t.ActualCost, t.Currency, models.DefaultCurrency, t.CreatedAt)
+				st.costSamples++
+				st.estimatedCost += estimated
+				st.actualCost += actual
+			}
+		}
+	}
+	return c
+}
+
+func calibrationKeys(taskType, model string) []calibrationKey {
+	return []calibrationKey{
+		{calibrationScopeTypeModel, taskType, model},
+		{calibrationScopeType, taskType, ""},
+		{calibrationScopeModel, "", model},
+		{calibrationScopeGlobal, "", ""},
+	}
+}
+
+// Factors returns the days and cost factors for a task type and model, taken from the most
+// specific scope with enough samples. Without enough history the factor is 1.
+func (c *Calibration) Factors(taskType, model string) (float64, float64) {
+	days, cost := 1.0, 1.0
+	daysFound, costFound := false, false
+	for _, key := range calibrationKeys(taskType, model) {
+		st := c.stats[key]
+		if st == nil {
+			continue
+		}
+		if !daysFound && st.samples >= minCalibrationSamples && st.estimatedDays > 0 {
+			days, daysFound = st.actualDays/st.estimatedDays, true
+		}
+		if !costFound && st.costSamples >= minCalibrationSamples && st.estimatedCost > 0 {
+			cost, costFound = st.actualCost/st.estimatedCost, true
+		}
+	}
+	return days, cost
+}
+
+// Apply records the estimating model on the task and sets its calibrated days and cost
+func (c *Calibration) Apply(t *models.Task, model string) {
+	daysFactor, costFactor := c.Factors(t.Type, model)
+	days := roundTo(float64(t.EstimatedDays)*daysFactor, 1)
+	cost := roundTo(t.EstimatedCost*costFactor, 2)
+	t.EstimateModel = model
+	t.CalibratedDays = &days
+	t.CalibratedCost = &cost
+}
+
+// List returns the factors of all scopes with enough samples, most specific first
+func (c *Calibration) List() []models.EstimationFactor {
+	factors := []models.EstimationFactor{}
+	for key, st := range c.stats {
+		if st.samples < minCalibrationSamples {
+			continue
+		}
+		f := models.EstimationFactor{
+			Scope:      key.scope,
+			TaskType:   key.taskType,
+			Model:      key.model,
+			Samples:    st.samples,
+			DaysFactor: roundTo(st.actualDays/st.estimatedDays, 3),
+			CostFactor: 1,
+		}
+		if st.costSamples >= minCalibrationSamples && st.estimatedCost > 0 {
+			f.CostFactor = roundTo(st.actualCost/st.estimatedCost, 3)
+		}
+		factors = append(factors, f)
+	}
+	order := map[string]int{calibrationScopeTypeModel: 0, calibrationScopeType: 1, calibrationScopeModel: 2, calibrationScopeGlobal: 3}
+	sort.Slice(factors, func(i, j int) bool {
+		a, b := factors[i], factors[j]
+		if order[a.Scope] != order[b.Scope] {
+			return order[a.Scope] < order[b.Scope]
+		}
+		if a.TaskType != b.TaskType {
+			return a.TaskType < b.TaskType
+		}
+		return a.Model < b.Model
+	})
+	return factors
+}
+
+// CalibrateTasks adds calibrated estimates to freshly generated tasks.
+// Calibration is advisory, so a failure to load history is logged and the tasks keep their raw estimates.
+func (s *Service) CalibrateTasks(tasks []models.Task, model string) {
+	c, err := s.LoadCalibration()
+	if err != nil {
+		slog.WarnContext(s.Context(), "Skipping estimate calibration", "error", err)
+		return
+	}
+	for i := range tasks {
+		c.Apply(&tasks[i], model)
+	}
+}
+
+// CompleteTask marks a task completed and records its actual days and, if known, its actual cost
+func (s *Service) CompleteTask(taskID string, actualDays float64, actualCost *float64) (*models.Task, error) {
+	if actualDays <= 0 {
+		return nil, fmt.Errorf("actual_days must be positive")
+	}
+	if actualCost != nil && *actualCost < 0 {
+		return nil, fmt.Errorf("actual_cost must not be negative")
+	}
+	var task models.Task
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&task, "id = ?", taskID).Error; err != nil {
+			return err
+		}
+		var children int64
+		if err := tx.Model(&models.Task{}).Where("parent_id = ?", taskID).Count(&children).Error; err != nil {
+			return err
+		}
+		if children > 0 {
+			return fmt.Errorf("%w: record actuals on the subtasks of %s", ErrTaskHasSubtasks, taskID)
+		}
+		now := time.Now()
+		if err := tx.Model(&task).Updates(map[string]interface{}{
+			"status":        "completed",
+			"progress":      100,
+			"current_phase": "finished",
+			"actual_days":   actualDays,
+			"actual_cost":   actualCost,
+			"completed_at":  now,
+			"updated_at":    now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := s.RollupTaskAncestors(tx, task.ParentID); err != nil {
+			return err
+		}
+		return tx.First(&task, "id = ?", taskID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// EstimationAccuracy compares raw and calibrated estimates of completed tasks with their actuals,
+// grouped by task type and estimating model. Factors always come from the full history;
+// scope limits the rows to the projects it selects, nil means all.
+func (s *Service) EstimationAccuracy(projectID string, scope func(*gorm.DB) *gorm.DB) (*models.EstimationAccuracyReport, error) {
+	all, err := s.completedEstimates("")
+	if err != nil {
+		return nil, fmt.Errorf("load estimation history failed: %w", err)
+	}
+	rates, err := s.LoadRateTable()
+	if err != nil {
+		return nil, err
+	}
+	calibration := newCalibration(all, rates)
+	tasks := all
+	if projectID != "" {
+		tasks, err = s.completedEstimates(projectID)
+		if err != nil {
+			return nil, fmt.Errorf("load estimation history failed: %w", err)
+		}
+	}
+	if scope != nil {
+		var visible []string
+		if err := s.DB.Model(&models.Project{}).Scopes(scope).Pluck("id", &visible).Error; err != nil {
+			return nil, fmt.Errorf("load projects failed: %w", err)
+		}
+		allowed := make(map[string]bool, len(visible))
+		for _, id := range visible {
+			allowed[id] = true
+		}
+		scoped := make([]models.Task, 0, len(tasks))
+		for _, t := range tasks {
+			if allowed[t.ProjectID] {
+				scoped = append(scoped, t)
+			}
+		}
+		tasks = scoped
+	}
+
+	type accuracyKey struct{ taskType, model string }
+	type accumulator struct {
+		samples, costSamples, calibrated, calibratedCost int
+		daysErr, costErr, calDaysErr, calCostErr         float64
+	}
+	groups := make(map[accuracyKey]*accumulator)
+	for _, t := range tasks {
+		key := accuracyKey{t.Type, t.EstimateModel}
+		acc := groups[key]
+		if acc == nil {
+			acc = &accumulator{}
+			groups[key] = acc
+		}
+		actualDays := *t.ActualDays
+		acc.samples++
+		acc.daysErr += math.Abs(float64(t.EstimatedDays)-actualDays) / actualDays
+		if t.CalibratedDays != nil {
+			acc.calibrated++
+			acc.calDaysErr += math.Abs(*t.CalibratedDays-actualDays) / actualDays
+		}
+		if t.ActualCost != nil && *t.ActualCost > 0 {
+			actualCost := *t.ActualCost
+			acc.costSamples++
+			acc.costErr += math.Abs(t.EstimatedCost-actualCost) / actualCost
+			if t.CalibratedCost != nil {
+				acc.calibratedCost++
+				acc.calCostErr += math.Abs(*t.CalibratedCost-actualCost) / actualCost
+			}
+		}
+	}
+
+	percent := func(sum float64, n int) *float64 {
+		if n == 0 {
+			return nil
+		}
+		v := roundTo(sum/float64(n)*100, 2)
+		return &v
+	}
+	rows := make([]models.EstimationAccuracyRow, 0, len(groups))
+	for key, acc := range groups {
+		daysFactor, costFactor := calibration.Factors(key.taskType, key.model)
+		rows = append(rows, models.EstimationAccuracyRow{
+			TaskType:            key.taskType,
+			Model:               key.model,
+			Samples:             acc.samples,
+			DaysError:           *percent(acc.daysErr, acc.samples),
+			CostError:           percent(acc.costErr, acc.costSamples),
+			CalibratedSamples:   acc.calibrated,
+			CalibratedDaysError: percent(acc.calDaysErr, acc.calibrated),
+			CalibratedCostError: percent(acc.calCostErr, acc.calibratedCost),
+			DaysFactor:          roundTo(daysFactor, 3),
+			CostFactor:          roundTo(costFactor, 3),
+		})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].TaskType != rows[j].TaskType {
+			return rows[i].TaskType < rows[j].TaskType
+		}
+		return rows[i].Model < rows[j].Model
+	})
+
+	return &models.EstimationAccuracyReport{
+		ProjectID:   projectID,
+		Rows:        rows,
+		Factors:     calibration.List(),
+		MinSamples:  minCalibrationSamples,
+		GeneratedAt: time.Now(),
+	}, nil
+}
+
+func roundTo(v float64, decimals int) float64 {
+	p := math.Pow(10, float64(decimals))
+	return math.Round(v*p) / p
+}
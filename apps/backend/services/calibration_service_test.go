@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+
+	"neuro-dev/models"
+)
+
+func TestExecutedTaskDoesNotChangeCalibration(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	project := &models.Project{ID: "p1", Locale: "en"}
+	if err := s.DB.Create(project).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	newTask := func(id string) *models.Task {
+		task := &models.Task{ID: id, ProjectID: "p1", Type: "后端开发", Status: "pending", ExpenseType: "budget",
+			EstimatedDays: 2, EstimatedCost: 1000, EstimateModel: "m1", Currency: models.DefaultCurrency}
+		if err := s.DB.Create(task).Error; err != nil {
+			t.Fatalf("create task: %v", err)
+		}
+		return task
+	}
+	cost := 1500.0
+	for _, id := range []string{"t1", "t2", "t3"} {
+		newTask(id)
+		if _, err := s.CompleteTask(id, 3, &cost); err != nil {
+			t.Fatalf("CompleteTask: %v", err)
+		}
+	}
+	before, err := s.LoadCalibration()
+	if err != nil {
+		t.Fatalf("LoadCalibration: %v", err)
+	}
+	days, costs := before.Factors("后端开发", "m1")
+	if days != 1.5 || costs != 1.5 {
+		t.Fatalf("factors from recorded actuals = %v, %v, want 1.5, 1.5", days, costs)
+	}
+
+	// Resume after the second to last phase so only one simulated phase runs
+	executed := newTask("t4")
+	executed.CurrentPhase = "TestErrorSummary"
+	s.ExecuteTask(executed, project)
+
+	var stored models.Task
+	if err := s.DB.First(&stored, "id = ?", "t4").Error; err != nil {
+		t.Fatalf("load task: %v", err)
+	}
+	if stored.Status != "completed" || stored.ActualDays != nil || stored.ActualCost != nil {
+		t.Errorf("executed task = status %s, actual days %v, actual cost %v; want completed without actuals",
+			stored.Status, stored.ActualDays, stored.ActualCost)
+	}
+	after, err := s.LoadCalibration()
+	if err != nil {
+		t.Fatalf("LoadCalibration: %v", err)
+	}
+	if d, c := after.Factors("后端开发", "m1"); d != days || c != costs {
+		t.Errorf("factors after execution = %v, %v, want %v, %v", d, c, days, costs)
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"neuro-dev/models"
+)
+
+type currencyPair struct {
+	Base  string
+	Quote string
+}
+
+// RateTable is an in-memory snapshot of the exchange rate table used during one aggregation.
+// It records the pairs that could not be converted so callers can report them.
+type RateTable struct {
+	rates   map[currencyPair][]models.ExchangeRate // sorted by EffectiveDate ascending
+	quotes  map[string][]string                    // base currency -> quote currencies, for triangulation
+	missing map[string]bool
+}
+
+// NormalizeCurrency validates an ISO 4217 style code and returns it upper-cased.
+// An empty code resolves to models.DefaultCurrency.
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return models.DefaultCurrency, nil
+	}
+	if len(code) != 3 {
+		return "", fmt.Errorf("invalid currency code: %s", code)
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return "", fmt.Errorf("invalid currency code: %s", code)
+		}
+	}
+	return code, nil
+}
+
+// LoadRateTable reads all exchange rates from the database
+func (s *Service) LoadRateTable() (*RateTable, error) {
+	return loadRateTable(s.DB)
+}
+
+func loadRateTable(db *gorm.DB) (*RateTable, error) {
+	var rates []models.ExchangeRate
+	if err := db.Order("effective_date asc").Find(&rates).Error; err != nil {
+		return nil, fmt.Errorf("load exchange rates failed: %w", err)
+	}
+	rt := &RateTable{
+		rates:   map[currencyPair][]models.ExchangeRate{},
+		quotes:  map[string][]string{},
+		missing: map[string]bool{},
+	}
+	for _, r := range rates {
+		if r.Rate <= 0 {
+			continue
+		}
+		pair := currencyPair{Base: r.BaseCurrency, Quote: r.QuoteCurrency}
+		if _, ok := rt.rates[pair]; !ok {
+			rt.quotes[r.BaseCurrency] = append(rt.quotes[r.BaseCurrency], r.QuoteCurrency)
+			rt.quotes[r.QuoteCurrency] = append(rt.quotes[r.QuoteCurrency], r.BaseCurrency)
+		}
+		rt.rates[pair] = append(rt.rates[pair], r)
+	}
+	return rt, nil
+}
+
+// Convert converts amount from one currency to another using the rate effective at the given time.
+// Direct, inverse and single-hop triangulated rates are tried in that order.
+// The second return value is false when no rate is available; the pair is then recorded as missing.
+func (rt *RateTable) Convert(amount float64, from, to string, at time.Time) (float64, bool) {
+	if from == "" {
+		from = models.DefaultCurrency
+	}
+	if to == "" {
+		to = models.DefaultCurrency
+	}
+	if from == to || amount == 0 {
+		return amount, true
+	}
+	if rate, ok := rt.rate(from, to, at); ok {
+		return amount * rate, true
+	}
+	for _, via := range rt.quotes[from] {
+		first, ok := rt.rate(from, via, at)
+		if !ok {
+			continue
+		}
+		if second, ok := rt.rate(via, to, at); ok {
+			return amount * first * second, true
+		}
+	}
+	rt.missing[from+"/"+to] = true
+	return 0, false
+}
+
+// Missing returns the currency pairs that could not be converted so far
+func (rt *RateTable) Missing() []string {
+	pairs := make([]string, 0, len(rt.missing))
+	for p := range rt.missing {
+		pairs = append(pairs, p)
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func (rt *RateTable) rate(from, to string, at time.Time) (float64, bool) {
+	if r, ok := effectiveRate(rt.rates[currencyPair{Base: from, Quote: to}], at); ok {
+		return r, true
+	}
+	if r, ok := effectiveRate(rt.rates[currencyPair{Base: to, Quote: from}], at); ok {
+		return 1 / r, true
+	}
+	return 0, false
+}
+
+// effectiveRate picks the latest rate effective on or before at, or the oldest rate when all are later
+func effectiveRate(rates []models.ExchangeRate, at time.Time) (float64, bool) {
+	if len(rates) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].EffectiveDate.After(at) })
+	if i == 0 {
+		return rates[0].Rate, true
+	}
+	return rates[i-1].Rate, true
+}
+
+// ProjectEstimatedCost sums the estimated cost of the project's leaf tasks in its reporting currency
+// and records unconvertible pairs on project.MissingRates.
+func (s *Service) ProjectEstimatedCost(project *models.Project, rt *RateTable) float64 {
+	target := project.ReportingCurrency
+	if target == "" {
+		target = models.DefaultCurrency
+	}
+	total := 0.0
+	missing := map[string]bool{}
+	for _, t := range LeafTasks(project.Tasks) {
+		amount, ok := rt.Convert(t.EstimatedCost, t.Currency, target, t.CreatedAt)
+		if !ok {
+			missing[t.Currency+"/"+target] = true
+			continue
+		}
+		total += amount
+	}
+	project.MissingRates = nil
+	for p := range missing {
+		project.MissingRates = append(project.MissingRates, p)
+	}
+	sort.Strings(project.MissingRates)
+	return total
+}
+
+// SaveExchangeRate inserts a rate or replaces the rate of the same pair and effective date
+func (s *Service) SaveExchangeRate(rate *models.ExchangeRate) error {
+	return saveExchangeRate(s.DB, rate)
+}
+
+func saveExchangeRate(db *gorm.DB, rate *models.ExchangeRate) error {
+	base, err := NormalizeCurrency(rate.BaseCurrency)
+	if err != nil {
+		return err
+	}
+	quote, err := NormalizeCurrency(rate.QuoteCurrency)
+	if err != nil {
+		return err
+	}
+	if base == quote {
+		return fmt.Errorf("base and quote currency must differ: %s", base)
+	}
+	if rate.Rate <= 0 {
+		return fmt.Errorf("rate must be positive: %v", rate.Rate)
+	}
+	rate.BaseCurrency = base
+	rate.QuoteCurrency = quote
+	y, m, d := rate.EffectiveDate.Date()
+	rate.EffectiveDate = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	rate.UpdatedAt = time.Now()
+	if rate.CreatedAt.IsZero() {
+		rate.CreatedAt = rate.UpdatedAt
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "base_currency"}, {Name: "quote_currency"}, {Name: "effective_date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "source", "updated_at"}),
+	}).Create(rate).Error
+}
+
+// ImportExchangeRates reads a CSV file with the header base_currency,quote_currency,rate,effective_date
+// and upserts every line in one transaction, so a bad line saves nothing. It returns the number of saved rates.
+func (s *Service) ImportExchangeRates(r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return 0, fmt.Errorf("read csv header failed: %w", err)
+	}
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	for _, col := range []string{"base_currency", "quote_currency", "rate", "effective_date"} {
+		if _, ok := index[col]; !ok {
+			return 0, fmt.Errorf("missing column: %s", col)
+		}
+	}
+
+	saved := 0
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		for line := 2; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read csv line %d failed: %w", line, err)
+			}
+			value, err := strconv.ParseFloat(strings.TrimSpace(record[index["rate"]]), 64)
+			if err != nil {
+				return fmt.Errorf("line %d: invalid rate %q", line, record[index["rate"]])
+			}
+			date, err := time.Parse("2006-01-02", strings.TrimSpace(record[index["effective_date"]]))
+			if err != nil {
+				return fmt.Errorf("line %d: invalid effective_date %q", line, record[index["effective_date"]])
+			}
+			rate := &models.ExchangeRate{
+				BaseCurrency:  record[index["base_currency"]],
+				QuoteCurrency: record[index["quote_currency"]],
+				Rate:          value,
+				EffectiveDate: date,
+				Source:        "import",
+			}
+			if err := saveExchangeRate(tx, rate); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			saved++
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return saved, nil
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"neuro-dev/models"
+)
+
+// Data scopes decide which rows of an organization a member can read when data permissions are enabled
+const (
+	DataScopeAll          = "all"          // every project of every organization
+	DataScopeOrganization = "organization" // every project of the member's organizations
+	DataScopeDepartment   = "department"   // projects created in the member's department, plus their own
+	DataScopeOwn          = "own"          // projects the member created
+)
+
+// defaultDataScopes applies to roles missing from settings.application.datascopes
+var defaultDataScopes = map[string]string{
+	models.OrgRoleOwner:   DataScopeOrganization,
+	models.OrgRoleManager: DataScopeDepartment,
+	models.OrgRoleViewer:  DataScopeOwn,
+}
+
+// IsDataScope reports whether scope is all, organization, department or own
+func IsDataScope(scope string) bool {
+	switch scope {
+	case DataScopeAll, DataScopeOrganization, DataScopeDepartment, DataScopeOwn:
+		return true
+	}
+	return false
+}
+
+// DataScopeRules validates the per-role data scopes from the settings and fills in the defaults
+func DataScopeRules(configured map[string]string) (map[string]string, error) {
+	rules := make(map[string]string, len(defaultDataScopes))
+	for role, scope := range defaultDataScopes {
+		rules[role] = scope
+	}
+	for role, scope := range configured {
+		if !IsOrgRole(role) {
+			return nil, fmt.Errorf("invalid data scope role %q, expected owner, manager or viewer", role)
+		}
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if !IsDataScope(scope) {
+			return nil, fmt.Errorf("invalid data scope %q for %s, expected all, organization, department or own", scope, role)
+		}
+		rules[role] = scope
+	}
+	return rules, nil
+}
+
+// dataScopeFilter builds the project condition for the caller's memberships under the data scope rules.
+// all reports that no condition applies.
+func (sc *Scope) dataScopeFilter() (cond string, args []interface{}, all bool) {
+	orgIDs := sc.OrgIDs()
+	sort.Slice(orgIDs, func(i, j int) bool { return orgIDs[i] < orgIDs[j] })
+	var conds []string
+	var whole []uint
+	for _, orgID := range orgIDs {
+		switch sc.DataScopes[sc.Roles[orgID]] {
+		case DataScopeAll:
+			return "", nil, true
+		case DataScopeDepartment:
+			conds = append(conds, "(organization_id = ? AND (created_by = ? OR department = ? AND department <> ''))")
+			args = append(args, orgID, sc.UserID, sc.Department)
+		case DataScopeOwn:
+			conds = append(conds, "(organization_id = ? AND created_by = ?)")
+			args = append(args, orgID, sc.UserID)
+		default:
+			whole = append(whole, orgID)
+		}
+	}
+	if len(whole) > 0 {
+		conds = append(conds, "organization_id IN ?")
+		args = append(args, whole)
+	}
+	if len(conds) == 0 {
+		return "1 = 0", nil, false
+	}
+	return strings.Join(conds, " OR "), args, false
+}
+
+// Tasks limits a task query to the tasks of projects the caller can see
+func (sc *Scope) Tasks(db *gorm.DB) *gorm.DB {
+	return sc.byProject(db)
+}
+
+// Bills limits a bill or bill rule query to the projects the caller can see; unmapped bills are admin only
+func (sc *Scope) Bills(db *gorm.DB) *gorm.DB {
+	return sc.byProject(db)
+}
+
+func (sc *Scope) byProject(db *gorm.DB) *gorm.DB {
+	if sc.Admin {
+		return db
+	}
+	return db.Where("project_id IN (?)", sc.ProjectIDs(db.Session(&gorm.Session{NewDB: true})))
+}
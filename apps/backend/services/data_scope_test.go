@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"gorm.io/gorm"
+	"neuro-dev/db"
+	"neuro-dev/models"
+)
+
+// seedDataScopes creates two organizations: alice and carol of department A and bob of department B own
+// the first, dave owns the second. Each user created one project.
+func seedDataScopes(t *testing.T, s *Service) map[string]uint {
+	t.Helper()
+	users := map[string]uint{}
+	for _, u := range []struct{ name, dept string }{{"alice", "A"}, {"bob", "B"}, {"carol", "A"}, {"dave", "A"}} {
+		user := models.User{Username: u.name, PasswordHash: "x", Department: u.dept}
+		if err := s.DB.Create(&user).Error; err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users[u.name] = user.ID
+	}
+	orgs := []models.Organization{{Name: "org1"}, {Name: "org2"}}
+	if err := s.DB.Create(&orgs).Error; err != nil {
+		t.Fatalf("create organizations: %v", err)
+	}
+	members := []models.OrganizationMember{
+		{OrganizationID: orgs[0].ID, UserID: users["alice"], Role: models.OrgRoleOwner},
+		{OrganizationID: orgs[0].ID, UserID: users["bob"], Role: models.OrgRoleOwner},
+		{OrganizationID: orgs[0].ID, UserID: users["carol"], Role: models.OrgRoleOwner},
+		{OrganizationID: orgs[1].ID, UserID: users["dave"], Role: models.OrgRoleOwner},
+	}
+	if err := s.DB.Create(&members).Error; err != nil {
+		t.Fatalf("create members: %v", err)
+	}
+	projects := []models.Project{
+		{ID: "p-alice", OrganizationID: orgs[0].ID, CreatedBy: users["alice"], Department: "A"},
+		{ID: "p-bob", OrganizationID: orgs[0].ID, CreatedBy: users["bob"], Department: "B"},
+		{ID: "p-carol", OrganizationID: orgs[0].ID, CreatedBy: users["carol"], Department: "A"},
+		{ID: "p-org2", OrganizationID: orgs[1].ID, CreatedBy: users["dave"], Department: "A"},
+	}
+	if err := s.DB.Create(&projects).Error; err != nil {
+		t.Fatalf("create projects: %v", err)
+	}
+	return users
+}
+
+func TestDataScopes(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	users := seedDataScopes(t, s)
+	all := []string{"p-alice", "p-bob", "p-carol", "p-org2"}
+
+	tests := []struct {
+		scope   string
+		user    string
+		visible []string
+	}{
+		{DataScopeAll, "alice", []string{"p-alice", "p-bob", "p-carol", "p-org2"}},
+		{DataScopeAll, "bob", []string{"p-alice", "p-bob", "p-carol", "p-org2"}},
+		{DataScopeOrganization, "alice", []string{"p-alice", "p-bob", "p-carol"}},
+		{DataScopeOrganization, "bob", []string{"p-alice", "p-bob", "p-carol"}},
+		{DataScopeDepartment, "alice", []string{"p-alice", "p-carol"}},
+		{DataScopeDepartment, "bob", []string{"p-bob"}},
+		{DataScopeOwn, "alice", []string{"p-alice"}},
+		{DataScopeOwn, "bob", []string{"p-bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope+"/"+tt.user, func(t *testing.T) {
+			s.DataScopes = map[string]string{models.OrgRoleOwner: tt.scope}
+			sc, err := s.UserScope(users[tt.user], false)
+			if err != nil {
+				t.Fatalf("UserScope: %v", err)
+			}
+
+			var listed []models.Project
+			if err := sc.Projects(s.DB).Find(&listed).Error; err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			ids := make([]string, 0, len(listed))
+			for _, p := range listed {
+				ids = append(ids, p.ID)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, tt.visible) {
+				t.Errorf("list = %v, want %v", ids, tt.visible)
+			}
+
+			visible := map[string]bool{}
+			for _, id := range tt.visible {
+				visible[id] = true
+			}
+			for _, id := range all {
+				for _, op := range []struct{ name, role string }{
+					{"get", models.OrgRoleViewer},
+					{"update", models.OrgRoleManager},
+					{"delete", models.OrgRoleOwner},
+				} {
+					_, err := s.AuthorizeProject(sc, id, op.role)
+					var want error
+					switch {
+					case !visible[id]:
+						want = gorm.ErrRecordNotFound
+					case id == "p-org2" && op.role != models.OrgRoleViewer:
+						// the all scope shows other organizations' projects but grants no role in them
+						want = ErrForbidden
+					}
+					if !errors.Is(err, want) {
+						t.Errorf("%s %s: err = %v, want %v", op.name, id, err, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBackfillProjectOwners(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	users := seedDataScopes(t, s)
+	// Go back to before 0008, which the projects below predate
+	states, err := db.MigrationStatus(s.DB)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	steps := 0
+	for _, st := range states {
+		if st.Version >= "0008" {
+			steps++
+		}
+	}
+	if _, err := db.MigrateDown(s.DB, steps); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	var org models.Organization
+	if err := s.DB.First(&org, "name = ?", "org1").Error; err != nil {
+		t.Fatalf("load organization: %v", err)
+	}
+	legacy := []models.Project{
+		{ID: "p-legacy", OrganizationID: org.ID},
+		{ID: "p-legacy-dept", OrganizationID: org.ID, Department: "B"},
+	}
+	if err := s.DB.Create(&legacy).Error; err != nil {
+		t.Fatalf("create projects: %v", err)
+	}
+	if _, err := db.MigrateUp(s.DB, MigrationData{ConfigDir: "../config"}); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	want := map[string]models.Project{
+		"p-legacy":      {CreatedBy: users["alice"], Department: "A"},
+		"p-legacy-dept": {CreatedBy: users["alice"], Department: "B"},
+		"p-bob":         {CreatedBy: users["bob"], Department: "B"},
+	}
+	for id, w := range want {
+		var p models.Project
+		if err := s.DB.First(&p, "id = ?", id).Error; err != nil {
+			t.Fatalf("load %s: %v", id, err)
+		}
+		if p.CreatedBy != w.CreatedBy || p.Department != w.Department {
+			t.Errorf("%s: created_by=%d department=%q, want %d %q", id, p.CreatedBy, p.Department, w.CreatedBy, w.Department)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"neuro-dev/metrics"
+	"neuro-dev/models"
+)
+
+// executions tracks background task runs so that shutdown can stop them between phases
+type executions struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newExecutions() *executions {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &executions{ctx: ctx, cancel: cancel}
+}
+
+// RunTask executes a task in the background until it completes or the service shuts down
+func (s *Service) RunTask(task *models.Task, project *models.Project) {
+	s.exec.wg.Add(1)
+	metrics.TasksRunning.Inc()
+	go func() {
+		defer s.exec.wg.Done()
+		defer metrics.TasksRunning.Dec()
+		s.ExecuteTask(task, project)
+	}()
+}
+
+// Stopping is closed when Shutdown begins
+func (s *Service) Stopping() <-chan struct{} {
+	return s.exec.ctx.Done()
+}
+
+// Shutdown asks running tasks to stop after their current phase and waits until they have
+// checkpointed or ctx expires
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.exec.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.exec.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for running tasks failed: %w", ctx.Err())
+	}
+}
+
+// checkpointTask stores the task's progress so an interrupted execution can resume after its last finished phase,
+// and rolls the progress up to the task's ancestors
+func (s *Service) checkpointTask(ctx context.Context, task *models.Task) {
+	if err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+			"status":        task.Status,
+			"current_phase": task.CurrentPhase,
+			"progress":      task.Progress,
+			"started_at":    task.StartedAt,
+			"completed_at":  task.CompletedAt,
+			"updated_at":    task.UpdatedAt,
+		}).Error; err != nil {
+			return err
+		}
+		return s.RollupTaskAncestors(tx, task.ParentID)
+	}); err != nil {
+		slog.ErrorContext(ctx, "Failed to checkpoint task", "error", err)
+	}
+}
+
+// ResetInterruptedTasks returns tasks left in progress by a previous process to pending so they can be
+// started again; they resume after their last checkpointed phase. A process that is shutting down checkpoints
+// its tasks within the shutdown timeout, so only tasks not updated for staleAfter are reset now; the more
+// recent ones are checked again once staleAfter has passed.
+func (s *Service) ResetInterruptedTasks(staleAfter time.Duration) (int64, error) {
+	n, err := s.resetStaleTasks(staleAfter, nil)
+	if err != nil {
+		return n, err
+	}
+	var recent []string
+	if err := s.DB.Model(&models.Task{}).Where("status = ?", "in_progress").Pluck("id", &recent).Error; err != nil {
+		return n, err
+	}
+	if len(recent) == 0 {
+		return n, nil
+	}
+	s.exec.wg.Add(1)
+	go func() {
+		defer s.exec.wg.Done()
+		select {
+		case <-s.exec.ctx.Done():
+			return
+		case <-time.After(staleAfter):
+		}
+		if n, err := s.resetStaleTasks(staleAfter, recent); err != nil {
+			slog.Error("Failed to reset interrupted tasks", "error", err)
+		} else if n > 0 {
+			slog.Info("Reset interrupted tasks to pending", "count", n)
+		}
+	}()
+	return n, nil
+}
+
+// resetStaleTasks sets in progress tasks not updated for staleAfter back to pending, limited to ids when given
+func (s *Service) resetStaleTasks(staleAfter time.Duration, ids []string) (int64, error) {
+	q := s.DB.Model(&models.Task{}).Where("status = ? AND updated_at < ?", "in_progress", time.Now().Add(-staleAfter))
+	if ids != nil {
+		q = q.Where("id IN ?", ids)
+	}
+	res := q.Updates(map[string]interface{}{"status": "pending", "updated_at": time.Now()})
+	return res.RowsAffected, res.Error
+}
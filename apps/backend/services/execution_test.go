@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"neuro-dev/models"
+)
+
+func TestResetInterruptedTasks(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	now := time.Now()
+	tasks := []models.Task{
+		{ID: "stale", ProjectID: "p1", Status: "in_progress", UpdatedAt: now.Add(-time.Hour)},
+		{ID: "recent", ProjectID: "p1", Status: "in_progress", UpdatedAt: now},
+		{ID: "queued", ProjectID: "p1", Status: "pending", UpdatedAt: now.Add(-time.Hour)},
+	}
+	if err := s.DB.Create(&models.Project{ID: "p1"}).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	if err := s.DB.Create(&tasks).Error; err != nil {
+		t.Fatalf("create tasks: %v", err)
+	}
+	status := func(id string) string {
+		var task models.Task
+		if err := s.DB.First(&task, "id = ?", id).Error; err != nil {
+			t.Fatalf("load %s: %v", id, err)
+		}
+		return task.Status
+	}
+
+	staleAfter := 200 * time.Millisecond
+	n, err := s.ResetInterruptedTasks(staleAfter)
+	if err != nil {
+		t.Fatalf("ResetInterruptedTasks: %v", err)
+	}
+	if n != 1 || status("stale") != "pending" {
+		t.Errorf("reset %d tasks, stale task is %s", n, status("stale"))
+	}
+	if got := status("recent"); got != "in_progress" {
+		t.Errorf("recently checkpointed task was reset to %s", got)
+	}
+
+	// Without further checkpoints the recent task is reset once staleAfter has passed
+	deadline := time.Now().Add(5 * time.Second)
+	for status("recent") != "pending" {
+		if time.Now().After(deadline) {
+			t.Fatal("recent task was not reset after staleAfter")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
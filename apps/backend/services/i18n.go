@@ -0,0 +1,111 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Supported locales. Chinese is the default and uses the unsuffixed config files.
+const (
+	LocaleZh      = "zh"
+	LocaleEn      = "en"
+	DefaultLocale = LocaleZh
+)
+
+// Locales lists the supported locales
+var Locales = []string{LocaleZh, LocaleEn}
+
+// localeConfigSuffix is appended to config file names for non-default locales, e.g. RoleConfig_English.json
+var localeConfigSuffix = map[string]string{
+	LocaleEn: "_English",
+}
+
+// NormalizeLocale maps zh, zh-CN, en-US, English and similar to a supported locale; empty means the default
+func NormalizeLocale(locale string) (string, error) {
+	l := strings.ToLower(strings.TrimSpace(locale))
+	switch {
+	case l == "":
+		return DefaultLocale, nil
+	case l == "zh" || strings.HasPrefix(l, "zh-") || strings.HasPrefix(l, "zh_") || l == "chinese":
+		return LocaleZh, nil
+	case l == "en" || strings.HasPrefix(l, "en-") || strings.HasPrefix(l, "en_") || l == "english":
+		return LocaleEn, nil
+	}
+	return "", fmt.Errorf("unsupported locale %q, expected %s", locale, strings.Join(Locales, " or "))
+}
+
+// ConfigPath returns the config file of a locale, e.g. config/RoleConfig_English.json for en.
+// Falls back to the default file when the locale has no file of its own.
+func ConfigPath(dir, name, locale string) string {
+	if suffix := localeConfigSuffix[locale]; suffix != "" {
+		ext := filepath.Ext(name)
+		path := filepath.Join(dir, strings.TrimSuffix(name, ext)+suffix+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(dir, name)
+}
+
+// messages is the catalog of server-generated text, keyed by locale and message key.
+// Values are fmt format strings.
+var messages = map[string]map[string]string{
+	LocaleZh: {
+		"log.project_starting":      "正在启动项目: %s",
+		"log.env_init":              "开始初始化项目环境...",
+		"log.tasks_generated":       "已生成 %d 个任务",
+		"log.task_created":          "任务 [%s]: 已创建，等待执行",
+		"log.task_running":          "任务 [%s]: 正在执行中...",
+		"log.task_completed":        "任务 [%s]: 执行完成 ✓",
+		"log.task_failed":           "任务 [%s]: 执行失败 ✗",
+		"log.analyzing":             "正在分析项目需求...",
+		"log.generating":            "正在生成项目任务...",
+		"log.project_created":       "项目已创建，准备创建任务...",
+		"log.project_running":       "项目正在运行中...",
+		"log.project_started":       "项目启动成功...",
+		"log.project_all_done":      "所有任务已完成!",
+		"log.project_completed":     "项目执行成功 🎉",
+		"log.project_failed":        "项目执行过程中出现错误",
+		"task.fallback_name":        "生成任务失败",
+		"task.fallback_requirement": "架构设计、数据库设计、接口设计",
+		"task.tool_description":     "提交分解后的开发任务列表",
+	},
+	LocaleEn: {
+		"log.project_starting":      "Starting project: %s",
+		"log.env_init":              "Initializing project environment...",
+		"log.tasks_generated":       "Generated %d tasks",
+		"log.task_created":          "Task [%s]: created, waiting to run",
+		"log.task_running":          "Task [%s]: running...",
+		"log.task_completed":        "Task [%s]: completed ✓",
+		"log.task_failed":           "Task [%s]: failed ✗",
+		"log.analyzing":             "Analyzing project requirements...",
+		"log.generating":            "Generating project tasks...",
+		"log.project_created":       "Project created, ready to create tasks...",
+		"log.project_running":       "Project is running...",
+		"log.project_started":       "Project started successfully...",
+		"log.project_all_done":      "All tasks completed!",
+		"log.project_completed":     "Project finished successfully 🎉",
+		"log.project_failed":        "An error occurred while running the project",
+		"task.fallback_name":        "Task generation failed",
+		"task.fallback_requirement": "Architecture design, database design, API design",
+		"task.tool_description":     "Submit the list of decomposed development tasks",
+	},
+}
+
+// T returns the catalog message for key in locale, formatted with args.
+// Missing entries fall back to the default locale and then to the key itself.
+func T(locale, key string, args ...interface{}) string {
+	format, ok := messages[locale][key]
+	if !ok {
+		format, ok = messages[DefaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
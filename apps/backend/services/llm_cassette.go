@@ -0,0 +1,317 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"neuro-dev/models"
+)
+
+var (
+	// ErrCassetteMismatch is returned in replay when a request differs from the recorded one
+	ErrCassetteMismatch = errors.New("request does not match cassette")
+	// ErrCassetteExhausted is returned in replay when more requests are made than were recorded
+	ErrCassetteExhausted = errors.New("cassette exhausted")
+	// ErrCassettesDisabled is returned when the service LLM client does not support cassettes
+	ErrCassettesDisabled = errors.New("cassettes are not enabled")
+)
+
+// Cassette is a recording of the LLM interactions of one project, in call order
+type Cassette struct {
+	ProjectID    string        `json:"project_id"`
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request and its reply or error
+type Interaction struct {
+	Model    string     `json:"model"`
+	Request  LLMRequest `json:"request"`
+	Reply    string     `json:"reply,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Duration string     `json:"duration"`
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette failed: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette failed: %w", err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette as indented JSON
+func (c *Cassette) Save(path string) error {
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// Recorder is an LLMClient that forwards to another client and appends every interaction to a cassette.
+// The file is rewritten after each call so a crashed run still leaves a usable recording.
+type Recorder struct {
+	mu       sync.Mutex
+	inner    LLMClient
+	cassette *Cassette
+	path     string
+}
+
+func NewRecorder(inner LLMClient, projectID, path string) *Recorder {
+	return &Recorder{
+		inner:    inner,
+		cassette: &Cassette{ProjectID: projectID, RecordedAt: time.Now(), Interactions: []Interaction{}},
+		path:     path,
+	}
+}
+
+// Complete implements LLMClient
+func (r *Recorder) Complete(ctx context.Context, model *models.Model, req LLMRequest) (string, error) {
+	start := time.Now()
+	reply, err := r.inner.Complete(ctx, model, req)
+	interaction := Interaction{
+		Model:    model.Name,
+		Request:  req,
+		Reply:    reply,
+		Duration: time.Since(start).Round(time.Millisecond).String(),
+	}
+	if err != nil {
+		interaction.Error = err.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if saveErr := r.cassette.Save(r.path); saveErr != nil {
+		return "", fmt.Errorf("save cassette failed: %w", saveErr)
+	}
+	return reply, err
+}
+
+// Replayer is an LLMClient that serves replies from a cassette in order.
+// Each request must match the recorded one in model, phase and messages.
+type Replayer struct {
+	mu       sync.Mutex
+	cassette *Cassette
+	next     int
+}
+
+func NewReplayer(cassette *Cassette) *Replayer {
+	return &Replayer{cassette: cassette}
+}
+
+// Complete implements LLMClient
+func (r *Replayer) Complete(ctx context.Context, model *models.Model, req LLMRequest) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next >= len(r.cassette.Interactions) {
+		return "", fmt.Errorf("%w after %d interactions", ErrCassetteExhausted, r.next)
+	}
+	i := r.next
+	recorded := r.cassette.Interactions[i]
+	if diff := diffInteraction(recorded, model.Name, req); diff != "" {
+		return "", fmt.Errorf("%w at interaction %d: %s", ErrCassetteMismatch, i+1, diff)
+	}
+	r.next++
+	if recorded.Error != "" {
+		return "", errors.New(recorded.Error)
+	}
+	return recorded.Reply, nil
+}
+
+// Remaining returns how many recorded interactions have not been replayed
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.cassette.Interactions) - r.next
+}
+
+func diffInteraction(recorded Interaction, model string, req LLMRequest) string {
+	if recorded.Model != model {
+		return fmt.Sprintf("model %q, recorded %q", model, recorded.Model)
+	}
+	if recorded.Request.Phase != req.Phase {
+		return fmt.Sprintf("phase %q, recorded %q", req.Phase, recorded.Request.Phase)
+	}
+	if len(recorded.Request.Messages) != len(req.Messages) {
+		return fmt.Sprintf("%d messages, recorded %d", len(req.Messages), len(recorded.Request.Messages))
+	}
+	for j, m := range req.Messages {
+		if !reflect.DeepEqual(m, recorded.Request.Messages[j]) {
+			return fmt.Sprintf("message %d differs", j+1)
+		}
+	}
+	return ""
+}
+
+// CassetteClient wraps the service LLM client and records or replays the calls of individual projects.
+// Projects without an active session go straight to the wrapped client.
+type CassetteClient struct {
+	mu       sync.Mutex
+	inner    LLMClient
+	dir      string
+	sessions map[string]LLMClient
+}
+
+func NewCassetteClient(inner LLMClient, dir string) *CassetteClient {
+	if dir == "" {
+		dir = "temp/cassettes"
+	}
+	return &CassetteClient{inner: inner, dir: dir, sessions: make(map[string]LLMClient)}
+}
+
+// Complete implements LLMClient
+func (c *CassetteClient) Complete(ctx context.Context, model *models.Model, req LLMRequest) (string, error) {
+	c.mu.Lock()
+	client, ok := c.sessions[req.ProjectID]
+	c.mu.Unlock()
+	if !ok || req.ProjectID == "" {
+		client = c.inner
+	}
+	return client.Complete(ctx, model, req)
+}
+
+func (c *CassetteClient) offline() bool {
+	return isOffline(c.inner)
+}
+
+// cassetteClient returns the cassette wrapper of the service LLM client
+func (s *Service) cassetteClient() (*CassetteClient, error) {
+	c, ok := s.LLM.(*CassetteClient)
+	if !ok {
+		return nil, ErrCassettesDisabled
+	}
+	return c, nil
+}
+
+// StartRecording records all further LLM calls of a project into a new cassette and returns its name
+func (s *Service) StartRecording(projectID string) (string, error) {
+	c, err := s.cassetteClient()
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, busy := c.sessions[projectID]; busy {
+		return "", fmt.Errorf("project %s already has an active cassette session", projectID)
+	}
+	name := fmt.Sprintf("%s-%s.json", projectID, time.Now().Format("20060102-150405"))
+	c.sessions[projectID] = NewRecorder(c.inner, projectID, filepath.Join(c.dir, name))
+	return name, nil
+}
+
+// StopRecording ends the recording session of a project
+func (s *Service) StopRecording(projectID string) error {
+	c, err := s.cassetteClient()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.sessions[projectID].(*Recorder); !ok {
+		return fmt.Errorf("project %s is not being recorded", projectID)
+	}
+	delete(c.sessions, projectID)
+	return nil
+}
+
+// IsRecording reports whether a project's LLM calls are being recorded
+func (s *Service) IsRecording(projectID string) bool {
+	c, err := s.cassetteClient()
+	if err != nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.sessions[projectID].(*Recorder)
+	return ok
+}
+
+// ListCassettes returns the cassette file names, optionally only those of one project, newest first
+func (s *Service) ListCassettes(projectID string) ([]string, error) {
+	c, err := s.cassetteClient()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		if projectID != "" && !strings.HasPrefix(e.Name(), projectID+"-") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+// LoadNamedCassette reads a cassette from the cassette directory
+func (s *Service) LoadNamedCassette(name string) (*Cassette, error) {
+	c, err := s.cassetteClient()
+	if err != nil {
+		return nil, err
+	}
+	if name == "" || name != filepath.Base(name) || !strings.HasSuffix(name, ".json") {
+		return nil, fmt.Errorf("invalid cassette name: %s", name)
+	}
+	return LoadCassette(filepath.Join(c.dir, name))
+}
+
+// ReplayProject re-runs the task generation of a project against a cassette instead of the provider.
+// Nothing is persisted; the tasks are returned so they can be compared with the original run.
+// A prompt that differs from the recording fails with ErrCassetteMismatch.
+func (s *Service) ReplayProject(project *models.Project, cassette *Cassette) ([]models.Task, int, error) {
+	c, err := s.cassetteClient()
+	if err != nil {
+		return nil, 0, err
+	}
+	if cassette.ProjectID != project.ID {
+		return nil, 0, fmt.Errorf("cassette belongs to project %s", cassette.ProjectID)
+	}
+	replayer := NewReplayer(cassette)
+	c.mu.Lock()
+	if _, busy := c.sessions[project.ID]; busy {
+		c.mu.Unlock()
+		return nil, 0, fmt.Errorf("project %s already has an active cassette session", project.ID)
+	}
+	c.sessions[project.ID] = replayer
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.sessions, project.ID)
+		c.mu.Unlock()
+	}()
+
+	tasks, err := s.GenerateTaskProposal(project)
+	if err != nil {
+		return nil, replayer.Remaining(), err
+	}
+	return tasks, replayer.Remaining(), nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"neuro-dev/models"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	fake := NewFakeLLM().On(PhaseGenerateTasks, "", fakeTaskReply)
+	client := NewCassetteClient(fake, t.TempDir())
+	s := newTestService(t, client)
+	project := &models.Project{ID: "p1", Model: "not-configured", Description: "A login feature", Locale: "en"}
+
+	name, err := s.StartRecording(project.ID)
+	if err != nil {
+		t.Fatalf("StartRecording: %v", err)
+	}
+	recorded, err := s.GenerateTaskProposal(project)
+	if err != nil {
+		t.Fatalf("GenerateTaskProposal: %v", err)
+	}
+	if err := s.StopRecording(project.ID); err != nil {
+		t.Fatalf("StopRecording: %v", err)
+	}
+
+	cassette, err := s.LoadNamedCassette(name)
+	if err != nil {
+		t.Fatalf("LoadNamedCassette: %v", err)
+	}
+	if len(cassette.Interactions) != 1 || cassette.Interactions[0].Reply != fakeTaskReply {
+		t.Fatalf("unexpected cassette: %+v", cassette)
+	}
+
+	// Replays must not reach the wrapped client and must produce the same tasks every time
+	for run := 0; run < 2; run++ {
+		replayed, remaining, err := s.ReplayProject(project, cassette)
+		if err != nil {
+			t.Fatalf("ReplayProject run %d: %v", run+1, err)
+		}
+		if remaining != 0 {
+			t.Errorf("run %d left %d interactions", run+1, remaining)
+		}
+		if !reflect.DeepEqual(comparableTasks(replayed), comparableTasks(recorded)) {
+			t.Errorf("run %d replayed %+v, recorded %+v", run+1, replayed, recorded)
+		}
+	}
+	if n := len(fake.Calls()); n != 1 {
+		t.Errorf("wrapped client got %d calls, want 1", n)
+	}
+}
+
+func TestCassetteReplayDetectsPromptChange(t *testing.T) {
+	fake := NewFakeLLM().On(PhaseGenerateTasks, "", fakeTaskReply)
+	s := newTestService(t, NewCassetteClient(fake, t.TempDir()))
+	project := &models.Project{ID: "p1", Model: "not-configured", Description: "A login feature", Locale: "en"}
+
+	name, err := s.StartRecording(project.ID)
+	if err != nil {
+		t.Fatalf("StartRecording: %v", err)
+	}
+	if _, err := s.GenerateTaskProposal(project); err != nil {
+		t.Fatalf("GenerateTaskProposal: %v", err)
+	}
+	if err := s.StopRecording(project.ID); err != nil {
+		t.Fatalf("StopRecording: %v", err)
+	}
+	cassette, err := s.LoadNamedCassette(name)
+	if err != nil {
+		t.Fatalf("LoadNamedCassette: %v", err)
+	}
+
+	project.Description = "A signup feature"
+	if _, _, err := s.ReplayProject(project, cassette); !errors.Is(err, ErrCassetteMismatch) {
+		t.Errorf("got %v, want ErrCassetteMismatch", err)
+	}
+}
+
+// comparableTasks drops the fields that differ on every run
+func comparableTasks(tasks []models.Task) []models.Task {
+	out := make([]models.Task, len(tasks))
+	for i, t := range tasks {
+		t.ID = ""
+		t.CreatedAt, t.UpdatedAt = time.Time{}, time.Time{}
+		out[i] = t
+	}
+	return out
+}
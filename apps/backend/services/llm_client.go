@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"neuro-dev/config"
+	"neuro-dev/metrics"
+	"neuro-dev/models"
+)
+
+// Phases identify what an LLM call is for, so fakes and recordings can tell calls apart
+const (
+	PhaseGenerateTasks = "generate_tasks"
+	PhaseDecomposeTask = "decompose_task"
+)
+
+// LLMMessage is one turn of a conversation with a model
+type LLMMessage struct {
+	Role    llms.ChatMessageType `json:"role"` // human, ai or system
+	Content string               `json:"content"`
+}
+
+// LLMRequest is a single chat call.
+// Structured requests ask for a task list in the model's structured output mode; the reply is then the JSON text.
+type LLMRequest struct {
+	ProjectID        string       `json:"project_id,omitempty"`
+	Phase            string       `json:"phase"`
+	Locale           string       `json:"locale,omitempty"`
+	Messages         []LLMMessage `json:"messages"`
+	Structured       bool         `json:"structured"`
+	MaxTokens        int          `json:"max_tokens,omitempty"`         // 0 uses the provider default
+	PromptTemplateID uint         `json:"prompt_template_id,omitempty"` // template the first message was rendered from
+	PromptVersion    int          `json:"prompt_version,omitempty"`
+}
+
+// LLMClient sends chat requests to a configured model and returns the reply text.
+// Service uses it for every model call so that it can be replaced by a fake or a recording.
+type LLMClient interface {
+	Complete(ctx context.Context, model *models.Model, req LLMRequest) (string, error)
+}
+
+// offlineClient is implemented by LLM clients that answer without calling a provider
+type offlineClient interface {
+	offline() bool
+}
+
+func isOffline(c LLMClient) bool {
+	o, ok := c.(offlineClient)
+	return ok && o.offline()
+}
+
+// LangchainClient is the LLMClient that talks to real providers through langchaingo
+type LangchainClient struct {
+	Settings config.LLM // defaults for models without their own provider, token or base URL
+}
+
+func NewLangchainClient(settings config.LLM) *LangchainClient {
+	return &LangchainClient{Settings: settings}
+}
+
+// Complete implements LLMClient
+func (c *LangchainClient) Complete(ctx context.Context, model *models.Model, req LLMRequest) (string, error) {
+	llm, mode, err := c.newLLM(ctx, model, req.Structured)
+	if err != nil {
+		return "", err
+	}
+
+	callOpts := append([]llms.CallOption{llms.WithTemperature(0.7)}, providerCallOptions(c.provider(model))...)
+	if req.MaxTokens > 0 {
+		callOpts = append(callOpts, llms.WithMaxTokens(req.MaxTokens))
+	}
+	switch mode {
+	case OutputModeJSONObject:
+		callOpts = append(callOpts, llms.WithJSONMode())
+	case OutputModeFunction:
+		callOpts = append(callOpts,
+			llms.WithTools([]llms.Tool{{
+				Type: "function",
+				Function: &llms.FunctionDefinition{
+					Name:        taskSchemaName,
+					Description: T(req.Locale, "task.tool_description"),
+					Parameters:  taskListSchema(),
+					Strict:      true,
+				},
+			}}),
+			llms.WithToolChoice(llms.ToolChoice{Type: "function", Function: &llms.FunctionReference{Name: taskSchemaName}}),
+		)
+	}
+
+	content := make([]llms.MessageContent, 0, len(req.Messages))
+	for _, m := range req.Messages {
+		content = append(content, llms.TextParts(m.Role, m.Content))
+	}
+	provider := c.provider(model)
+	started := time.Now()
+	response, err := llm.GenerateContent(ctx, content, callOpts...)
+	metrics.LLMDuration.WithLabelValues(provider, model.Name).Observe(time.Since(started).Seconds())
+	if err == nil && len(response.Choices) == 0 {
+		err = errors.New("LLM returned no choices")
+	}
+	if err != nil {
+		metrics.LLMRequests.WithLabelValues(provider, model.Name, "error").Inc()
+		return "", err
+	}
+	metrics.LLMRequests.WithLabelValues(provider, model.Name, "ok").Inc()
+	prompt, completion := usageTokens(response.Choices)
+	metrics.LLMTokens.WithLabelValues(provider, model.Name, "prompt").Add(float64(prompt))
+	metrics.LLMTokens.WithLabelValues(provider, model.Name, "completion").Add(float64(completion))
+	return replyText(response.Choices, mode), nil
+}
+
+// usageTokens returns the token usage providers report in the generation info. OpenAI and Ollama call them
+// prompt and completion tokens, Anthropic input and output tokens; every choice repeats the same totals.
+func usageTokens(choices []*llms.ContentChoice) (prompt, completion int) {
+	for _, choice := range choices {
+		info := choice.GenerationInfo
+		prompt, completion = intInfo(info, "PromptTokens", "InputTokens"), intInfo(info, "CompletionTokens", "OutputTokens")
+		if prompt > 0 || completion > 0 {
+			return prompt, completion
+		}
+	}
+	return 0, 0
+}
+
+func intInfo(info map[string]any, keys ...string) int {
+	for _, key := range keys {
+		if n, ok := info[key].(int); ok {
+			return n
+		}
+	}
+	return 0
+}
+
+// replyText returns the reply body; in function mode the arguments of the task tool call.
+// Some providers return text and tool calls as separate choices, so all choices are searched.
+func replyText(choices []*llms.ContentChoice, mode string) string {
+	if mode == OutputModeFunction {
+		for _, choice := range choices {
+			for _, call := range choice.ToolCalls {
+				if call.FunctionCall != nil && call.FunctionCall.Name == taskSchemaName {
+					return call.FunctionCall.Arguments
+				}
+			}
+		}
+	}
+	var parts []string
+	for _, choice := range choices {
+		if choice.Content != "" {
+			parts = append(parts, choice.Content)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
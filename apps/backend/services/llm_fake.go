@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"neuro-dev/models"
+)
+
+// ErrNoFakeReply is returned by FakeLLM when no rule matches a request
+var ErrNoFakeReply = errors.New("no scripted reply")
+
+// FakeLLM is a deterministic LLMClient that answers from scripted rules, for running without a provider.
+// Rules are tried in the order they were added; the first whose phase and pattern match answers.
+type FakeLLM struct {
+	mu    sync.Mutex
+	rules []*fakeRule
+	calls []LLMRequest
+}
+
+type fakeRule struct {
+	phase   string
+	pattern *regexp.Regexp
+	replies []string
+	err     error
+	used    int
+}
+
+func NewFakeLLM() *FakeLLM {
+	return &FakeLLM{}
+}
+
+// On scripts replies for requests of phase (empty for any) whose last message matches pattern (empty for any).
+// Replies are returned in turn and the last one repeats, which makes it easy to script a bad reply followed by a repaired one.
+func (f *FakeLLM) On(phase, pattern string, replies ...string) *FakeLLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, &fakeRule{phase: phase, pattern: compileFakePattern(pattern), replies: replies})
+	return f
+}
+
+// OnError makes matching requests fail with err
+func (f *FakeLLM) OnError(phase, pattern string, err error) *FakeLLM {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rules = append(f.rules, &fakeRule{phase: phase, pattern: compileFakePattern(pattern), err: err})
+	return f
+}
+
+// Calls returns the requests received so far
+func (f *FakeLLM) Calls() []LLMRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]LLMRequest(nil), f.calls...)
+}
+
+// Complete implements LLMClient
+func (f *FakeLLM) Complete(ctx context.Context, model *models.Model, req LLMRequest) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, req)
+
+	last := ""
+	if len(req.Messages) > 0 {
+		last = req.Messages[len(req.Messages)-1].Content
+	}
+	for _, r := range f.rules {
+		if r.phase != "" && r.phase != req.Phase {
+			continue
+		}
+		if r.pattern != nil && !r.pattern.MatchString(last) {
+			continue
+		}
+		if r.err != nil {
+			return "", r.err
+		}
+		if len(r.replies) == 0 {
+			return "", nil
+		}
+		i := r.used
+		if i >= len(r.replies) {
+			i = len(r.replies) - 1
+		}
+		r.used++
+		return r.replies[i], nil
+	}
+	return "", fmt.Errorf("%w for phase %q", ErrNoFakeReply, req.Phase)
+}
+
+func (f *FakeLLM) offline() bool {
+	return true
+}
+
+func compileFakePattern(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	return regexp.MustCompile(pattern)
+}
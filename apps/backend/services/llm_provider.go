@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/cohere"
+	"github.com/tmc/langchaingo/llms/ollama"
+	"github.com/tmc/langchaingo/llms/openai"
+	"neuro-dev/models"
+)
+
+// LLM providers supported by models.Model.Provider
+const (
+	ProviderOpenAI    = "openai"    // OpenAI and any OpenAI-compatible API (DeepSeek, Qwen, vLLM, ...)
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+	ProviderOllama    = "ollama"    // local models served by Ollama
+	ProviderCohere    = "cohere"
+)
+
+// Providers lists the supported providers in display order
+var Providers = []string{ProviderOpenAI, ProviderAnthropic, ProviderOllama, ProviderCohere}
+
+// anthropicMaxTokens overrides the client default of 2048, which truncates longer task lists
+const anthropicMaxTokens = 4096
+
+// IsProvider reports whether provider is supported; empty means the default from settings.llm.provider
+func IsProvider(provider string) bool {
+	if provider == "" {
+		return true
+	}
+	for _, p := range Providers {
+		if p == provider {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsOutputMode reports whether a provider can honour a structured output mode.
+// Every provider supports text mode.
+func SupportsOutputMode(provider, mode string) bool {
+	switch mode {
+	case "", OutputModeText:
+		return true
+	case OutputModeJSONObject:
+		return provider == ProviderOpenAI || provider == ProviderOllama
+	case OutputModeJSONSchema:
+		return provider == ProviderOpenAI
+	case OutputModeFunction:
+		return provider == ProviderOpenAI || provider == ProviderAnthropic
+	}
+	return false
+}
+
+// provider returns the provider of a model, falling back to settings.llm.provider and then OpenAI
+func (c *LangchainClient) provider(m *models.Model) string {
+	if p := strings.ToLower(strings.TrimSpace(m.Provider)); p != "" {
+		return p
+	}
+	if p := strings.ToLower(strings.TrimSpace(c.Settings.Provider)); p != "" {
+		return p
+	}
+	return ProviderOpenAI
+}
+
+// newLLM builds a langchaingo client for the model's provider.
+// Token and base URL fall back to settings.llm when the model uses the configured provider.
+// The returned mode is the model's structured output mode, downgraded to text if the provider cannot honour it.
+func (c *LangchainClient) newLLM(ctx context.Context, m *models.Model, structured bool) (llms.Model, string, error) {
+	provider := c.provider(m)
+	mode := m.StructuredOutput
+	if mode == "" || !structured {
+		mode = OutputModeText
+	}
+	if !SupportsOutputMode(provider, mode) {
+		slog.WarnContext(ctx, "Provider does not support the output mode, using text", "model", m.Name, "provider", provider, "mode", mode)
+		mode = OutputModeText
+	}
+
+	token, baseURL := m.Token, m.BaseURL
+	if strings.EqualFold(provider, c.Settings.Provider) {
+		if token == "" {
+			token = c.Settings.ApiKey
+		}
+		if baseURL == "" {
+			baseURL = c.Settings.BaseURL
+		}
+	}
+	httpClient := http.DefaultClient
+	if c.Settings.Timeout > 0 {
+		httpClient = &http.Client{Timeout: time.Duration(c.Settings.Timeout) * time.Second}
+	}
+
+	switch provider {
+	case ProviderOpenAI:
+		opts := []openai.Option{
+			openai.WithToken(token),
+			openai.WithModel(m.Name),
+			openai.WithHTTPClient(httpClient),
+		}
+		if baseURL != "" {
+			opts = append(opts, openai.WithBaseURL(baseURL))
+		}
+		if mode == OutputModeJSONSchema {
+			opts = append(opts, openai.WithResponseFormat(taskListResponseFormat()))
+		}
+		llm, err := openai.New(opts...)
+		return llm, mode, err
+	case ProviderAnthropic:
+		opts := []anthropic.Option{
+			anthropic.WithToken(token),
+			anthropic.WithModel(m.Name),
+			anthropic.WithHTTPClient(httpClient),
+		}
+		if baseURL != "" {
+			opts = append(opts, anthropic.WithBaseURL(baseURL))
+		}
+		llm, err := anthropic.New(opts...)
+		return llm, mode, err
+	case ProviderOllama:
+		opts := []ollama.Option{
+			ollama.WithModel(m.Name),
+			ollama.WithHTTPClient(httpClient),
+		}
+		if baseURL != "" {
+			opts = append(opts, ollama.WithServerURL(baseURL))
+		}
+		llm, err := ollama.New(opts...)
+		return llm, mode, err
+	case ProviderCohere:
+		opts := []cohere.Option{
+			cohere.WithToken(token),
+			cohere.WithModel(m.Name),
+		}
+		if baseURL != "" {
+			opts = append(opts, cohere.WithBaseURL(baseURL))
+		}
+		llm, err := cohere.New(opts...)
+		return llm, mode, err
+	}
+	return nil, mode, fmt.Errorf("unsupported LLM provider: %s", provider)
+}
+
+// providerCallOptions returns call options required by a provider
+func providerCallOptions(provider string) []llms.CallOption {
+	if provider == ProviderAnthropic {
+		return []llms.CallOption{llms.WithMaxTokens(anthropicMaxTokens)}
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+package services
+
+import "neuro-dev/db"
+
+// MigrationData supplies the data migrations with the prompt seeds of the config directory and the
+// token cipher of the settings
+type MigrationData struct {
+	ConfigDir string
+	Tokens    *TokenCipher
+}
+
+// PromptSeeds returns the built-in prompts and the phase and role prompts of ConfigDir
+func (d MigrationData) PromptSeeds() ([]db.PromptSeed, error) {
+	return promptSeeds(d.ConfigDir)
+}
+
+// EncryptToken encrypts or re-wraps token with the current key and keeps it as it is without a key
+func (d MigrationData) EncryptToken(token string) (string, bool, error) {
+	if !d.Tokens.Enabled() {
+		return token, false, nil
+	}
+	return d.Tokens.Rewrap(token)
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+
+	"neuro-dev/db"
+	"neuro-dev/models"
+)
+
+func TestMigrationsSeedPromptTemplates(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	for _, want := range []struct{ kind, key, locale string }{
+		{models.PromptKindTaskGeneration, "", LocaleZh},
+		{models.PromptKindTaskGeneration, "", LocaleEn},
+		{models.PromptKindRole, "Chief Executive Officer", LocaleEn},
+	} {
+		var tmpl models.PromptTemplate
+		if err := s.DB.First(&tmpl, "kind = ? AND prompt_key = ? AND locale = ?", want.kind, want.key, want.locale).Error; err != nil {
+			t.Errorf("%s prompt %q (%s) was not seeded: %v", want.kind, want.key, want.locale, err)
+			continue
+		}
+		if tmpl.Version != 1 || !tmpl.Active || tmpl.Content == "" {
+			t.Errorf("seeded %s prompt %q (%s) = %+v", want.kind, want.key, want.locale, tmpl)
+		}
+	}
+}
+
+func TestMigrationsEncryptModelTokens(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	// Go back to before 0011 and store a token the way earlier builds did
+	if _, err := db.MigrateDown(s.DB, 1); err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	model := models.Model{Name: "m1", Token: "sk-plain"}
+	if err := s.DB.Create(&model).Error; err != nil {
+		t.Fatalf("create model: %v", err)
+	}
+	tokens := newTestCipher(t, newTokenKey(t))
+	if _, err := db.MigrateUp(s.DB, MigrationData{ConfigDir: "../config", Tokens: tokens}); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	var stored models.Model
+	if err := s.DB.First(&stored, model.ID).Error; err != nil {
+		t.Fatalf("load model: %v", err)
+	}
+	if !IsEncryptedToken(stored.Token) {
+		t.Fatalf("token left in plain text: %q", stored.Token)
+	}
+	if plain, err := tokens.Decrypt(stored.Token); err != nil || plain != "sk-plain" {
+		t.Errorf("Decrypt = %q, %v", plain, err)
+	}
+}
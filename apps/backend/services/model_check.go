@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"neuro-dev/models"
+)
+
+// PhaseModelCheck marks the minimal completion sent by a connectivity check
+const PhaseModelCheck = "model_check"
+
+// Connectivity check statuses; everything except ModelCheckOK is an error class
+const (
+	ModelCheckOK       = "ok"
+	ModelCheckAuth     = "auth"
+	ModelCheckNotFound = "not_found"
+	ModelCheckNetwork  = "network"
+	ModelCheckQuota    = "quota"
+	ModelCheckUnknown  = "unknown"
+)
+
+// modelCheckTimeout bounds a connectivity check, independent of settings.llm.timeout
+const modelCheckTimeout = 15 * time.Second
+
+// modelResolver is implemented by clients that know which provider model a configured model maps to
+type modelResolver interface {
+	ResolveModel(m *models.Model) string
+}
+
+// ResolveModel returns provider/model as it is sent to the provider
+func (c *LangchainClient) ResolveModel(m *models.Model) string {
+	return c.provider(m) + "/" + m.Name
+}
+
+// ResolveModel implements modelResolver by asking the wrapped client
+func (c *CassetteClient) ResolveModel(m *models.Model) string {
+	if r, ok := c.inner.(modelResolver); ok {
+		return r.ResolveModel(m)
+	}
+	return m.Name
+}
+
+// CheckModel sends a minimal completion to a model and stores the outcome as its last check.
+// A failed call is not an error: it is reported in the result with a classified status.
+func (s *Service) CheckModel(id uint) (*models.ModelCheckResult, error) {
+	var m models.Model
+	if err := s.DB.First(&m, id).Error; err != nil {
+		return nil, err
+	}
+
+	result := &models.ModelCheckResult{ModelID: m.ID, Model: m.Name, ResolvedModel: m.Name}
+	if r, ok := s.LLM.(modelResolver); ok {
+		result.ResolvedModel = r.ResolveModel(&m)
+	}
+
+	decrypted, err := s.decryptModel(&m)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), modelCheckTimeout)
+	defer cancel()
+	start := time.Now()
+	_, err = s.LLM.Complete(ctx, decrypted, LLMRequest{
+		Phase:     PhaseModelCheck,
+		Messages:  []LLMMessage{{Role: llms.ChatMessageTypeHuman, Content: "ping"}},
+		MaxTokens: 1,
+	})
+	result.LatencyMs = time.Since(start).Milliseconds()
+	result.CheckedAt = time.Now()
+	result.Status = ModelCheckOK
+	if err != nil {
+		result.Status = ClassifyModelError(err)
+		result.Error = err.Error()
+	}
+	result.OK = result.Status == ModelCheckOK
+
+	if err := s.DB.Model(&m).Updates(map[string]interface{}{
+		"last_check_at":         result.CheckedAt,
+		"last_check_status":     result.Status,
+		"last_check_latency_ms": result.LatencyMs,
+		"last_check_model":      result.ResolvedModel,
+		"last_check_error":      result.Error,
+	}).Error; err != nil {
+		return nil, fmt.Errorf("save model check failed: %w", err)
+	}
+	return result, nil
+}
+
+// ClassifyModelError maps a provider error to a check status. langchaingo surfaces HTTP failures
+// as "API returned unexpected status code: N: message", so status codes and messages are both matched.
+func ClassifyModelError(err error) string {
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) {
+		return ModelCheckNetwork
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case containsAny(msg, "status code: 401", "status code: 403", "api key", "api_key", "unauthorized", "authentication", "permission denied", "missing the token", "missing token"):
+		return ModelCheckAuth
+	case containsAny(msg, "status code: 429", "status code: 402", "quota", "rate limit", "rate_limit", "insufficient", "billing", "credit"):
+		return ModelCheckQuota
+	case containsAny(msg, "status code: 404", "model_not_found", "not found", "does not exist", "unknown model", "no such model"):
+		return ModelCheckNotFound
+	case containsAny(msg, "connection refused", "no such host", "timeout", "deadline exceeded", "connection reset", "eof", "tls", "unsupported protocol scheme", "dial tcp"):
+		return ModelCheckNetwork
+	}
+	return ModelCheckUnknown
+}
+
+func containsAny(s string, subs ...string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
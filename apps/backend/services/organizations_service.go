@@ -0,0 +1,320 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"neuro-dev/models"
+)
+
+var (
+	// ErrForbidden is returned when the caller can see a resource but lacks the role to change it
+	ErrForbidden = errors.New("insufficient organization role")
+	// ErrLastOwner is returned when removing or demoting the only owner of an organization
+	ErrLastOwner = errors.New("organization must keep at least one owner")
+)
+
+// orgRoleRank orders organization roles; a higher rank includes the permissions of lower ones
+var orgRoleRank = map[string]int{
+	models.OrgRoleViewer:  1,
+	models.OrgRoleManager: 2,
+	models.OrgRoleOwner:   3,
+}
+
+// IsOrgRole reports whether role is owner, manager or viewer
+func IsOrgRole(role string) bool {
+	return orgRoleRank[role] > 0
+}
+
+// Scope describes what a caller may access. System admins see every organization;
+// other users see the organizations they are members of, with their role in each.
+// With data permissions enabled, DataScopes further narrows the projects per role.
+type Scope struct {
+	UserID     uint
+	Admin      bool
+	Roles      map[uint]string   // organization ID -> role
+	Department string            // the caller's department, stamped on the projects they create
+	DataScopes map[string]string // role -> data scope; nil when data permissions are disabled
+}
+
+// UserScope loads the memberships of a user
+func (s *Service) UserScope(userID uint, admin bool) (*Scope, error) {
+	scope := &Scope{UserID: userID, Admin: admin, Roles: make(map[uint]string), DataScopes: s.DataScopes}
+	var user models.User
+	if err := s.DB.Select("department").First(&user, userID).Error; err != nil {
+		return nil, fmt.Errorf("load user failed: %w", err)
+	}
+	scope.Department = user.Department
+	var members []models.OrganizationMember
+	if err := s.DB.Where("user_id = ?", userID).Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("load memberships failed: %w", err)
+	}
+	for _, m := range members {
+		scope.Roles[m.OrganizationID] = m.Role
+	}
+	return scope, nil
+}
+
+// OrgIDs returns the organizations the caller is a member of
+func (sc *Scope) OrgIDs() []uint {
+	ids := make([]uint, 0, len(sc.Roles))
+	for id := range sc.Roles {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Can reports whether the caller has at least role in an organization
+func (sc *Scope) Can(orgID uint, role string) bool {
+	if sc.Admin {
+		return true
+	}
+	return orgRoleRank[sc.Roles[orgID]] >= orgRoleRank[role]
+}
+
+// Projects limits a project query to the caller's organizations, or to the rows their data scopes allow
+func (sc *Scope) Projects(db *gorm.DB) *gorm.DB {
+	if sc.Admin {
+		return db
+	}
+	if sc.DataScopes == nil {
+		return db.Where("organization_id IN ?", sc.OrgIDs())
+	}
+	cond, args, all := sc.dataScopeFilter()
+	if all {
+		return db
+	}
+	return db.Where(cond, args...)
+}
+
+// ProjectIDs is a subquery of the IDs of the projects the caller can see
+func (sc *Scope) ProjectIDs(db *gorm.DB) *gorm.DB {
+	return sc.Projects(db.Model(&models.Project{}).Select("id"))
+}
+
+// Models limits a model query to shared models and models of the caller's organizations
+func (sc *Scope) Models(db *gorm.DB) *gorm.DB {
+	if sc.Admin {
+		return db
+	}
+	return db.Where("organization_id IS NULL OR organization_id IN ?", sc.OrgIDs())
+}
+
+// AuthorizeProject loads a project the caller can see and checks that they have at least role in its
+// organization. Invisible projects are reported as gorm.ErrRecordNotFound so their existence is not revealed.
+func (s *Service) AuthorizeProject(sc *Scope, projectID, role string) (*models.Project, error) {
+	var project models.Project
+	if err := sc.Projects(s.DB).First(&project, "id = ?", projectID).Error; err != nil {
+		return nil, err
+	}
+	// A visible project can always be read; the all data scope also shows other organizations' projects
+	if role != models.OrgRoleViewer && !sc.Can(project.OrganizationID, role) {
+		return nil, ErrForbidden
+	}
+	return &project, nil
+}
+
+// AuthorizeTask checks role on the project of a task and returns the task
+func (s *Service) AuthorizeTask(sc *Scope, taskID, role string) (*models.Task, error) {
+	var task models.Task
+	if err := s.DB.First(&task, "id = ?", taskID).Error; err != nil {
+		return nil, err
+	}
+	if _, err := s.AuthorizeProject(sc, task.ProjectID, role); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// AuthorizeModel checks that the caller can see a model and change it: shared models need a system
+// admin, organization models at least role in their organization.
+func (s *Service) AuthorizeModel(sc *Scope, id uint, role string) (*models.Model, error) {
+	var model models.Model
+	if err := sc.Models(s.DB).First(&model, id).Error; err != nil {
+		return nil, err
+	}
+	if model.OrganizationID == nil {
+		if !sc.Admin {
+			return nil, ErrForbidden
+		}
+	} else if !sc.Can(*model.OrganizationID, role) {
+		return nil, ErrForbidden
+	}
+	return &model, nil
+}
+
+// ModelAvailable reports whether a project of orgID may use the model: shared models and the organization's own
+func (s *Service) ModelAvailable(name string, orgID uint) (bool, error) {
+	var count int64
+	err := s.DB.Model(&models.Model{}).
+		Where("name = ? AND (organization_id IS NULL OR organization_id = ?)", name, orgID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ListOrganizations returns the caller's organizations with their role; admins get all of them
+func (s *Service) ListOrganizations(sc *Scope) ([]models.Organization, error) {
+	query := s.DB.Order("name")
+	if !sc.Admin {
+		query = query.Where("id IN ?", sc.OrgIDs())
+	}
+	var orgs []models.Organization
+	if err := query.Find(&orgs).Error; err != nil {
+		return nil, err
+	}
+	for i := range orgs {
+		orgs[i].Role = sc.Roles[orgs[i].ID]
+	}
+	return orgs, nil
+}
+
+// CreateOrganization creates an organization owned by the caller
+func (s *Service) CreateOrganization(sc *Scope, name string) (*models.Organization, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, errors.New("organization name is required")
+	}
+	org := &models.Organization{Name: name, Role: models.OrgRoleOwner}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Organization{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("organization %s already exists", name)
+		}
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{OrganizationID: org.ID, UserID: sc.UserID, Role: models.OrgRoleOwner}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	sc.Roles[org.ID] = models.OrgRoleOwner
+	return org, nil
+}
+
+// DeleteOrganization removes an empty organization and its memberships
+func (s *Service) DeleteOrganization(orgID uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var projects, modelCount int64
+		if err := tx.Model(&models.Project{}).Where("organization_id = ?", orgID).Count(&projects).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Model{}).Where("organization_id = ?", orgID).Count(&modelCount).Error; err != nil {
+			return err
+		}
+		if projects > 0 || modelCount > 0 {
+			return fmt.Errorf("organization still has %d projects and %d models", projects, modelCount)
+		}
+		if err := tx.Where("organization_id = ?", orgID).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Organization{}, orgID).Error
+	})
+}
+
+// ListMembers returns the members of an organization with their usernames
+func (s *Service) ListMembers(orgID uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	if err := s.DB.Where("organization_id = ?", orgID).Order("id").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	if err := s.fillMemberNames(members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+func (s *Service) fillMemberNames(members []models.OrganizationMember) error {
+	ids := make([]uint, 0, len(members))
+	for _, m := range members {
+		ids = append(ids, m.UserID)
+	}
+	var users []models.User
+	if err := s.DB.Select("id", "username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return err
+	}
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+	for i := range members {
+		members[i].Username = names[members[i].UserID]
+	}
+	return nil
+}
+
+// SetMember adds a user to an organization or changes their role
+func (s *Service) SetMember(orgID uint, req models.OrganizationMemberRequest) (*models.OrganizationMember, error) {
+	if !IsOrgRole(req.Role) {
+		return nil, fmt.Errorf("invalid role %q, expected owner, manager or viewer", req.Role)
+	}
+	var user models.User
+	query := s.DB.Select("id", "username")
+	if req.UserID != 0 {
+		query = query.Where("id = ?", req.UserID)
+	} else {
+		query = query.Where("username = ?", strings.TrimSpace(req.Username))
+	}
+	if err := query.First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+
+	var member models.OrganizationMember
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("organization_id = ? AND user_id = ?", orgID, user.ID).First(&member).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			member = models.OrganizationMember{OrganizationID: orgID, UserID: user.ID, Role: req.Role}
+			return tx.Create(&member).Error
+		}
+		if err != nil {
+			return err
+		}
+		if member.Role == models.OrgRoleOwner && req.Role != models.OrgRoleOwner {
+			if err := ensureOtherOwner(tx, orgID, user.ID); err != nil {
+				return err
+			}
+		}
+		member.Role = req.Role
+		return tx.Model(&member).Updates(map[string]interface{}{"role": req.Role, "updated_at": time.Now()}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	member.Username = user.Username
+	return &member, nil
+}
+
+// RemoveMember removes a user from an organization; the last owner cannot be removed
+func (s *Service) RemoveMember(orgID, userID uint) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var member models.OrganizationMember
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+			return err
+		}
+		if member.Role == models.OrgRoleOwner {
+			if err := ensureOtherOwner(tx, orgID, userID); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&member).Error
+	})
+}
+
+func ensureOtherOwner(tx *gorm.DB, orgID, userID uint) error {
+	var owners int64
+	if err := tx.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role = ? AND user_id <> ?", orgID, models.OrgRoleOwner, userID).
+		Count(&owners).Error; err != nil {
+		return err
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
@@ -1,10 +1,11 @@
 package services
 
 import (
-	"log"
+	"log/slog"
 	"time"
 
 	"github.com/google/uuid"
+	"neuro-dev/logging"
 	"neuro-dev/models"
 )
 
@@ -38,5 +39,5 @@ func (s *Service) ExecuteProject(project *models.Project) {
 	}
 
 	project.UpdatedAt = time.Now()
-	log.Printf("Project %s execution finished with status: %s", project.ID, project.Status)
+	slog.InfoContext(logging.With(s.Context(), "project_id", project.ID), "Project execution finished", "status", project.Status)
 }
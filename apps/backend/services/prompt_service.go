@@ -0,0 +1,351 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+	"gorm.io/gorm"
+	"neuro-dev/db"
+	"neuro-dev/models"
+)
+
+var (
+	// ErrActivePromptDelete is returned when deleting the active version of a prompt
+	ErrActivePromptDelete = errors.New("active prompt version cannot be deleted")
+	// ErrNoActivePrompt is returned when a prompt has no active version and no built-in text
+	ErrNoActivePrompt = errors.New("no active prompt template")
+)
+
+// taskListFormats is the reply format shared by all task generation prompts, per locale.
+// Task types stay in Chinese in every locale because they are validated as identifiers.
+var taskListFormats = map[string]string{
+	LocaleZh: `{
+  "tasks": [
+    {
+      "name": "任务名称",
+      "description": "详细描述",
+      "type": "后端服务研发",
+      "priority": 1,
+      "assigned_role": "程序员",
+      "requirements": "具体要实现的功能",
+      "estimated_days": 5,
+      "estimated_cost": 12000
+    }
+  ]
+}`,
+	LocaleEn: `{
+  "tasks": [
+    {
+      "name": "Task name",
+      "description": "Detailed description",
+      "type": "后端服务研发",
+      "priority": 1,
+      "assigned_role": "Programmer",
+      "requirements": "Functionality to implement",
+      "estimated_days": 5,
+      "estimated_cost": 12000
+    }
+  ]
+}`,
+}
+
+// builtinPrompts are used when no version of a task prompt is active and seed version 1, per locale.
+// Placeholders: {vendors} {task_types} {description} {format} for generation;
+// {task_types} {name} {description} {requirements} {estimated_days} {estimated_cost} {format} for decomposition.
+var builtinPrompts = map[string]map[string]string{
+	LocaleZh: {
+		models.PromptKindTaskGeneration: `作为一个资深的软件架构师，请参考以下云厂商的功能：
+云厂商：{vendors}
+将以下项目描述分解成具体的开发任务。每个任务应该包含：任务名称、详细描述、类型（{task_types}）、优先级（1-3，1最高）、负责角色、具体要求、预计研发天数、预计研发费用。
+项目描述：{description}
+
+请以JSON格式返回任务列表，格式如下：
+{format}
+
+请生成任务列表，按优先级排序。只返回JSON，不要包含任何其他文字。`,
+		models.PromptKindTaskDecomposition: `作为一个资深的软件架构师，请将以下开发任务分解成更小的、可以独立完成的子任务。每个子任务应该包含：任务名称、详细描述、类型（{task_types}）、优先级（1-3，1最高）、负责角色、具体要求、预计研发天数、预计研发费用。
+子任务的预计研发天数和费用之和应与原任务的估算大致相当。
+任务名称：{name}
+任务描述：{description}
+具体要求：{requirements}
+预计研发天数：{estimated_days}
+预计研发费用：{estimated_cost}
+
+请以JSON格式返回子任务列表，格式如下：
+{format}
+
+请生成子任务列表，按执行顺序排序。只返回JSON，不要包含任何其他文字。`,
+	},
+	LocaleEn: {
+		models.PromptKindTaskGeneration: `As a senior software architect, take the capabilities of the following cloud vendors into account:
+Cloud vendors: {vendors}
+Break the project description below into concrete development tasks. Each task must include: name, detailed description, type (one of {task_types}, written exactly as given), priority (1-3, 1 is highest), assigned role, requirements, estimated development days and estimated development cost.
+Project description: {description}
+
+Return the task list as JSON in the following format:
+{format}
+
+Order the tasks by priority. Return only the JSON, without any other text.`,
+		models.PromptKindTaskDecomposition: `As a senior software architect, break the development task below into smaller subtasks that can each be completed independently. Each subtask must include: name, detailed description, type (one of {task_types}, written exactly as given), priority (1-3, 1 is highest), assigned role, requirements, estimated development days and estimated development cost.
+The days and costs of the subtasks should add up to roughly the estimate of the original task.
+Task name: {name}
+Task description: {description}
+Requirements: {requirements}
+Estimated days: {estimated_days}
+Estimated cost: {estimated_cost}
+
+Return the subtask list as JSON in the following format:
+{format}
+
+Order the subtasks in execution order. Return only the JSON, without any other text.`,
+	},
+}
+
+// IsPromptKind reports whether kind is a known prompt template kind
+func IsPromptKind(kind string) bool {
+	switch kind {
+	case models.PromptKindTaskGeneration, models.PromptKindTaskDecomposition, models.PromptKindPhase, models.PromptKindRole:
+		return true
+	}
+	return false
+}
+
+// RenderPrompt replaces {name} placeholders with vars; unknown placeholders are left as they are
+func RenderPrompt(content string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(content)
+}
+
+// promptRequest starts an LLM request whose first message is a rendered template
+func promptRequest(projectID, phase string, tmpl *models.PromptTemplate, prompt string) LLMRequest {
+	return LLMRequest{
+		ProjectID:        projectID,
+		Phase:            phase,
+		Locale:           tmpl.Locale,
+		Messages:         []LLMMessage{{Role: llms.ChatMessageTypeHuman, Content: prompt}},
+		PromptTemplateID: tmpl.ID,
+		PromptVersion:    tmpl.Version,
+	}
+}
+
+// ActivePrompt returns the active version of a prompt in a locale. Task prompts fall back to the
+// built-in text of the locale (ID and version 0) when no version is active.
+func (s *Service) ActivePrompt(kind, key, locale string) (*models.PromptTemplate, error) {
+	if locale == "" {
+		locale = DefaultLocale
+	}
+	var tmpl models.PromptTemplate
+	err := s.DB.Where("kind = ? AND prompt_key = ? AND locale = ? AND active = ?", kind, key, locale, true).First(&tmpl).Error
+	if err == nil {
+		return &tmpl, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("load prompt template failed: %w", err)
+	}
+	if content, ok := builtinPrompts[locale][kind]; ok && key == "" {
+		return &models.PromptTemplate{Kind: kind, Locale: locale, Content: content, Author: "system", Active: true}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s %s", ErrNoActivePrompt, locale, kind, key)
+}
+
+// ListPromptTemplates returns prompt versions filtered by kind, key and locale, newest version first
+func (s *Service) ListPromptTemplates(kind, key, locale string, activeOnly bool) ([]models.PromptTemplate, error) {
+	query := s.DB.Model(&models.PromptTemplate{})
+	if kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if locale != "" {
+		query = query.Where("locale = ?", locale)
+	}
+	if key != "" {
+		query = query.Where("prompt_key = ?", key)
+	}
+	if activeOnly {
+		query = query.Where("active = ?", true)
+	}
+	templates := []models.PromptTemplate{}
+	if err := query.Order("kind, prompt_key, locale, version desc").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// CreatePromptVersion stores content as the next version of a prompt, optionally activating it
+func (s *Service) CreatePromptVersion(req models.CreatePromptTemplateRequest) (*models.PromptTemplate, error) {
+	if !IsPromptKind(req.Kind) {
+		return nil, fmt.Errorf("invalid prompt kind: %s", req.Kind)
+	}
+	if strings.TrimSpace(req.Content) == "" {
+		return nil, fmt.Errorf("content is required")
+	}
+	if (req.Kind == models.PromptKindPhase || req.Kind == models.PromptKindRole) && req.Key == "" {
+		return nil, fmt.Errorf("key is required for %s prompts", req.Kind)
+	}
+	if req.Kind == models.PromptKindTaskGeneration || req.Kind == models.PromptKindTaskDecomposition {
+		req.Key = ""
+	}
+	locale, err := NormalizeLocale(req.Locale)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl := &models.PromptTemplate{
+		Kind:    req.Kind,
+		Key:     req.Key,
+		Locale:  locale,
+		Content: req.Content,
+		Author:  req.Author,
+		Note:    req.Note,
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.PromptTemplate{}).Where("kind = ? AND prompt_key = ? AND locale = ?", req.Kind, req.Key, locale).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		tmpl.Version = latest + 1
+		tmpl.CreatedAt = time.Now()
+		tmpl.UpdatedAt = time.Now()
+		if err := tx.Create(tmpl).Error; err != nil {
+			return err
+		}
+		if req.Activate {
+			return activatePrompt(tx, tmpl)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// ActivatePromptVersion makes a version the active one of its prompt; activating an older version rolls back
+func (s *Service) ActivatePromptVersion(id uint) (*models.PromptTemplate, error) {
+	var tmpl models.PromptTemplate
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&tmpl, id).Error; err != nil {
+			return err
+		}
+		return activatePrompt(tx, &tmpl)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+func activatePrompt(tx *gorm.DB, tmpl *models.PromptTemplate) error {
+	now := time.Now()
+	if err := tx.Model(&models.PromptTemplate{}).Where("kind = ? AND prompt_key = ? AND locale = ? AND id <> ?", tmpl.Kind, tmpl.Key, tmpl.Locale, tmpl.ID).
+		Updates(map[string]interface{}{"active": false, "updated_at": now}).Error; err != nil {
+		return err
+	}
+	tmpl.Active = true
+	tmpl.UpdatedAt = now
+	return tx.Model(tmpl).Updates(map[string]interface{}{"active": true, "updated_at": now}).Error
+}
+
+// DeletePromptVersion removes an inactive version
+func (s *Service) DeletePromptVersion(id uint) error {
+	var tmpl models.PromptTemplate
+	if err := s.DB.First(&tmpl, id).Error; err != nil {
+		return err
+	}
+	if tmpl.Active {
+		return ErrActivePromptDelete
+	}
+	return s.DB.Delete(&tmpl).Error
+}
+
+// ActiveRolePrompts returns the active role prompts of a locale by role, split into lines as in RoleConfig.json
+func (s *Service) ActiveRolePrompts(locale string) (map[string][]string, error) {
+	var templates []models.PromptTemplate
+	if err := s.DB.Where("kind = ? AND locale = ? AND active = ?", models.PromptKindRole, locale, true).Find(&templates).Error; err != nil {
+		return nil, fmt.Errorf("load role prompts failed: %w", err)
+	}
+	roles := make(map[string][]string, len(templates))
+	for _, t := range templates {
+		roles[t.Key] = strings.Split(t.Content, "\n")
+	}
+	return roles, nil
+}
+
+// configPhase is a phase of ChatConfig.json; composed phases list their sub-phases in Composition
+type configPhase struct {
+	Phase       string        `json:"phase"`
+	PhasePrompt []string      `json:"phase_prompt"`
+	Composition []configPhase `json:"composition"`
+}
+
+// promptSeeds returns version 1 of every prompt for every locale: the built-in task prompts, the phase
+// prompts of ChatConfig.json and the role prompts of RoleConfig.json (or their locale variants) in configDir
+func promptSeeds(configDir string) ([]db.PromptSeed, error) {
+	type seedKey struct{ kind, key, locale string }
+	seeds := map[seedKey]string{}
+	for _, locale := range Locales {
+		for kind, content := range builtinPrompts[locale] {
+			seeds[seedKey{kind, "", locale}] = content
+		}
+
+		var chat struct {
+			ChainConfig struct {
+				Phases []configPhase `json:"phases"`
+			} `json:"chain_config"`
+		}
+		if err := readConfigJSON(ConfigPath(configDir, "ChatConfig.json", locale), &chat); err != nil {
+			return nil, fmt.Errorf("read phase prompts failed: %w", err)
+		}
+		var addPhases func(phases []configPhase)
+		addPhases = func(phases []configPhase) {
+			for _, p := range phases {
+				if content := strings.TrimSpace(strings.Join(p.PhasePrompt, "\n")); content != "" {
+					seeds[seedKey{models.PromptKindPhase, p.Phase, locale}] = content
+				}
+				addPhases(p.Composition)
+			}
+		}
+		addPhases(chat.ChainConfig.Phases)
+
+		roles := map[string][]string{}
+		if err := readConfigJSON(ConfigPath(configDir, "RoleConfig.json", locale), &roles); err != nil {
+			return nil, fmt.Errorf("read role prompts failed: %w", err)
+		}
+		for role, lines := range roles {
+			seeds[seedKey{models.PromptKindRole, role, locale}] = strings.Join(lines, "\n")
+		}
+	}
+
+	list := make([]db.PromptSeed, 0, len(seeds))
+	for k, content := range seeds {
+		list = append(list, db.PromptSeed{Kind: k.kind, Key: k.key, Locale: k.locale, Content: content})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Locale != b.Locale {
+			return a.Locale < b.Locale
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Key < b.Key
+	})
+	return list, nil
+}
+
+func readConfigJSON(path string, v interface{}) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
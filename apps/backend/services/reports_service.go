@@ -0,0 +1,188 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"neuro-dev/models"
+)
+
+// BillTaskType is the task type under which imported cloud bills are reported
+const BillTaskType = "cloud_bill"
+
+type varianceKey struct {
+	ProjectID string
+	TaskType  string
+	Period    string
+}
+
+// BudgetVarianceReport compares budget tasks with cost tasks (and optionally imported bills)
+// per project, task type and period. Tasks are bucketed by creation time, bills by billing period start.
+// Amounts are converted to each project's reporting currency. From is inclusive, To is exclusive.
+func (s *Service) BudgetVarianceReport(q models.VarianceQuery) (*models.VarianceReport, error) {
+	interval := q.Interval
+	if interval == "" {
+		interval = "month"
+	}
+	if interval != "month" && interval != "quarter" && interval != "year" && interval != "all" {
+		return nil, fmt.Errorf("unsupported interval: %s", interval)
+	}
+
+	projectQuery := s.DB.Model(&models.Project{})
+	if q.ProjectID != "" {
+		projectQuery = projectQuery.Where("id = ?", q.ProjectID)
+	}
+	if q.Projects != nil {
+		projectQuery = projectQuery.Scopes(q.Projects)
+	}
+	var projects []models.Project
+	if err := projectQuery.Find(&projects).Error; err != nil {
+		return nil, fmt.Errorf("load projects failed: %w", err)
+	}
+	names := make(map[string]string, len(projects))
+	currencies := make(map[string]string, len(projects))
+	ids := make([]string, 0, len(projects))
+	for _, p := range projects {
+		names[p.ID] = p.Name
+		currencies[p.ID] = p.ReportingCurrency
+		if currencies[p.ID] == "" {
+			currencies[p.ID] = models.DefaultCurrency
+		}
+		ids = append(ids, p.ID)
+	}
+	rt, err := s.LoadRateTable()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.VarianceReport{
+		Interval:    interval,
+		From:        q.From,
+		To:          q.To,
+		Rows:        []models.VarianceRow{},
+		Projects:    []models.VarianceRow{},
+		GeneratedAt: time.Now(),
+	}
+	if len(ids) == 0 {
+		return report, nil
+	}
+
+	taskQuery := s.DB.Where("project_id IN ?", ids)
+	if q.From != nil {
+		taskQuery = taskQuery.Where("created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		taskQuery = taskQuery.Where("created_at < ?", *q.To)
+	}
+	var tasks []models.Task
+	if err := taskQuery.Find(&tasks).Error; err != nil {
+		return nil, fmt.Errorf("load tasks failed: %w", err)
+	}
+	// Parents carry rolled-up sums of their subtasks and are skipped to avoid double counting
+	var parentIDs []string
+	if err := s.DB.Model(&models.Task{}).Where("project_id IN ? AND parent_id <> ?", ids, "").Distinct().Pluck("parent_id", &parentIDs).Error; err != nil {
+		return nil, fmt.Errorf("load task hierarchy failed: %w", err)
+	}
+	isParent := make(map[string]bool, len(parentIDs))
+	for _, id := range parentIDs {
+		isParent[id] = true
+	}
+
+	rows := map[varianceKey]*models.VarianceRow{}
+	totals := map[string]*models.VarianceRow{}
+	add := func(projectID, taskType string, at time.Time, amount float64, currency string, actual bool) {
+		converted, ok := rt.Convert(amount, currency, currencies[projectID], at)
+		if !ok {
+			return
+		}
+		budget := converted
+		if actual {
+			budget = 0
+		} else {
+			converted = 0
+		}
+		key := varianceKey{ProjectID: projectID, TaskType: taskType, Period: periodLabel(at, interval)}
+		row, ok := rows[key]
+		if !ok {
+			row = &models.VarianceRow{ProjectID: projectID, ProjectName: names[projectID], TaskType: taskType, Period: key.Period, Currency: currencies[projectID]}
+			rows[key] = row
+		}
+		row.Budget += budget
+		row.Actual += converted
+		total, ok := totals[projectID]
+		if !ok {
+			total = &models.VarianceRow{ProjectID: projectID, ProjectName: names[projectID], Currency: currencies[projectID]}
+			totals[projectID] = total
+		}
+		total.Budget += budget
+		total.Actual += converted
+	}
+
+	for _, t := range tasks {
+		if isParent[t.ID] {
+			continue
+		}
+		add(t.ProjectID, t.Type, t.CreatedAt, t.EstimatedCost, t.Currency, t.ExpenseType == "cost")
+	}
+
+	if q.IncludeBills {
+		billQuery := s.DB.Where("project_id IN ?", ids)
+		if q.From != nil {
+			billQuery = billQuery.Where("period_start >= ?", *q.From)
+		}
+		if q.To != nil {
+			billQuery = billQuery.Where("period_start < ?", *q.To)
+		}
+		var bills []models.Bill
+		if err := billQuery.Find(&bills).Error; err != nil {
+			return nil, fmt.Errorf("load bills failed: %w", err)
+		}
+		for _, b := range bills {
+			add(b.ProjectID, BillTaskType, b.PeriodStart, b.Amount, b.Currency, true)
+		}
+	}
+
+	report.MissingRates = rt.Missing()
+	for _, row := range rows {
+		finishVarianceRow(row)
+		report.Rows = append(report.Rows, *row)
+	}
+	for _, total := range totals {
+		finishVarianceRow(total)
+		report.Projects = append(report.Projects, *total)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.ProjectName != b.ProjectName {
+			return a.ProjectName < b.ProjectName
+		}
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		return a.TaskType < b.TaskType
+	})
+	// Largest overruns first so finance sees them at the top
+	sort.Slice(report.Projects, func(i, j int) bool { return report.Projects[i].Variance > report.Projects[j].Variance })
+	return report, nil
+}
+
+func finishVarianceRow(row *models.VarianceRow) {
+	row.Variance = row.Actual - row.Budget
+	if row.Budget != 0 {
+		pct := row.Variance / row.Budget * 100
+		row.VariancePercent = &pct
+	}
+}
+
+func periodLabel(t time.Time, interval string) string {
+	switch interval {
+	case "year":
+		return t.Format("2006")
+	case "quarter":
+		return fmt.Sprintf("%d-Q%d", t.Year(), (int(t.Month())-1)/3+1)
+	case "all":
+		return "all"
+	}
+	return t.Format("2006-01")
+}
@@ -1,24 +1,55 @@
 package services
 
 import (
+	"context"
+
 	"gorm.io/gorm"
+	"neuro-dev/config"
 	"neuro-dev/models"
 )
 
 type Service struct {
 	DB             *gorm.DB
 	ModelService   *ModelService
+	LLM            LLMClient         // client for all model calls; replace with FakeLLM to run offline
+	Tokens         *TokenCipher      // encrypts model tokens at rest; nil stores them as given
+	JWT            config.JWT        // signing secret and token lifetimes
+	DataScopes     map[string]string // row-level data scope per organization role; nil disables data permissions
 	Projects       map[string]*models.Project
 	Tasks          map[string]*models.Task
+	exec           *executions
+	ctx            context.Context // carries the log attributes of the request, see WithContext
 	projectCounter int
 	taskCounter    int
 }
 
-func NewService(db *gorm.DB) *Service {
+// NewService creates the service; llm may be nil to use langchaingo with default settings
+func NewService(db *gorm.DB, llm LLMClient) *Service {
+	if llm == nil {
+		llm = NewLangchainClient(config.LLM{})
+	}
 	return &Service{
 		DB:           db,
 		ModelService: NewModelService(db),
+		LLM:          llm,
 		Projects:     make(map[string]*models.Project),
 		Tasks:        make(map[string]*models.Task),
+		exec:         newExecutions(),
+	}
+}
+
+// WithContext returns a copy of the service whose log lines carry the attributes of ctx, such as the request ID.
+// Only values are taken from ctx; its cancellation does not stop background work started with the copy.
+func (s *Service) WithContext(ctx context.Context) *Service {
+	c := *s
+	c.ctx = context.WithoutCancel(ctx)
+	return &c
+}
+
+// Context returns the context set by WithContext, or the background context
+func (s *Service) Context() context.Context {
+	if s.ctx == nil {
+		return context.Background()
 	}
+	return s.ctx
 }
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"neuro-dev/config"
+	"neuro-dev/db"
+)
+
+// newTestService returns a service on a migrated SQLite database in a temporary directory
+func newTestService(t *testing.T, llm LLMClient) *Service {
+	t.Helper()
+	conn, err := db.Init(&config.Settings{Database: config.Database{
+		Driver: "sqlite3",
+		Source: filepath.Join(t.TempDir(), "test.db"),
+	}})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if _, err := db.MigrateUp(conn, MigrationData{ConfigDir: "../config"}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := conn.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return NewService(conn, llm)
+}
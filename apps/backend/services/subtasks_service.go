@@ -0,0 +1,200 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"neuro-dev/models"
+)
+
+// ErrTaskCycle is returned when a task would become its own ancestor
+var ErrTaskCycle = errors.New("task hierarchy cycle")
+
+// maxTaskDepth bounds hierarchy walks so corrupted data cannot loop forever
+const maxTaskDepth = 32
+
+// DescendantTaskIDs returns the IDs of all subtasks below taskID, breadth first
+func (s *Service) DescendantTaskIDs(tx *gorm.DB, taskID string) ([]string, error) {
+	var ids []string
+	frontier := []string{taskID}
+	for depth := 0; len(frontier) > 0; depth++ {
+		if depth > maxTaskDepth {
+			return nil, fmt.Errorf("%w: below task %s", ErrTaskCycle, taskID)
+		}
+		var children []string
+		if err := tx.Model(&models.Task{}).Where("parent_id IN ?", frontier).Pluck("id", &children).Error; err != nil {
+			return nil, err
+		}
+		ids = append(ids, children...)
+		frontier = children
+	}
+	return ids, nil
+}
+
+// ValidateTaskParent checks that parentID is a task of the same project and not taskID itself or one of its subtasks
+func (s *Service) ValidateTaskParent(tx *gorm.DB, projectID, taskID, parentID string) error {
+	var parent models.Task
+	if err := tx.Select("id", "project_id", "parent_id").First(&parent, "id = ?", parentID).Error; err != nil {
+		return fmt.Errorf("parent task not found: %s", parentID)
+	}
+	if parent.ProjectID != projectID {
+		return fmt.Errorf("parent task %s belongs to another project", parentID)
+	}
+	if taskID == "" {
+		return nil
+	}
+	for depth, id := 0, parentID; id != ""; depth++ {
+		if id == taskID || depth > maxTaskDepth {
+			return fmt.Errorf("%w: %s cannot be moved below %s", ErrTaskCycle, taskID, parentID)
+		}
+		var t models.Task
+		if err := tx.Select("id", "parent_id").First(&t, "id = ?", id).Error; err != nil {
+			return err
+		}
+		id = t.ParentID
+	}
+	return nil
+}
+
+// RollupTaskAncestors recomputes EstimatedDays, EstimatedCost and Progress of taskID (if it has
+// subtasks) and of every ancestor above it. Days and costs are sums of the children, costs converted
+// to the parent's currency; progress is weighted by the children's estimated days.
+// Calibrated estimates are summed too when any child has one, using raw estimates for the others.
+func (s *Service) RollupTaskAncestors(tx *gorm.DB, taskID string) error {
+	var rates *RateTable
+	for depth, id := 0, taskID; id != ""; depth++ {
+		if depth > maxTaskDepth {
+			return fmt.Errorf("%w: above task %s", ErrTaskCycle, taskID)
+		}
+		var task models.Task
+		if err := tx.First(&task, "id = ?", id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		var children []models.Task
+		if err := tx.Where("parent_id = ?", id).Find(&children).Error; err != nil {
+			return err
+		}
+		if len(children) > 0 {
+			if rates == nil {
+				rt, err := loadRateTable(tx)
+				if err != nil {
+					return err
+				}
+				rates = rt
+			}
+			days, cost, progress := rollupChildren(children, task.Currency, rates)
+			updates := map[string]interface{}{
+				"estimated_days": days,
+				"estimated_cost": cost,
+				"progress":       progress,
+				"updated_at":     time.Now(),
+			}
+			if calDays, calCost, ok := rollupCalibrated(children, task.Currency, rates); ok {
+				updates["calibrated_days"] = calDays
+				updates["calibrated_cost"] = calCost
+			}
+			if err := tx.Model(&models.Task{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		id = task.ParentID
+	}
+	return nil
+}
+
+func rollupChildren(children []models.Task, currency string, rates *RateTable) (int, float64, int) {
+	days, cost := 0, 0.0
+	weighted := 0
+	plain := 0
+	for _, c := range children {
+		days += c.EstimatedDays
+		if amount, ok := rates.Convert(c.EstimatedCost, c.Currency, currency, c.CreatedAt); ok {
+			cost += amount
+		}
+		weighted += c.Progress * c.EstimatedDays
+		plain += c.Progress
+	}
+	if days > 0 {
+		return days, cost, weighted / days
+	}
+	return days, cost, plain / len(children)
+}
+
+func rollupCalibrated(children []models.Task, currency string, rates *RateTable) (float64, float64, bool) {
+	days, cost := 0.0, 0.0
+	calibrated := false
+	for _, c := range children {
+		d, amount := float64(c.EstimatedDays), c.EstimatedCost
+		if c.CalibratedDays != nil && c.CalibratedCost != nil {
+			d, amount, calibrated = *c.CalibratedDays, *c.CalibratedCost, true
+		}
+		days += d
+		if converted, ok := rates.Convert(amount, c.Currency, currency, c.CreatedAt); ok {
+			cost += converted
+		}
+	}
+	return roundTo(days, 1), roundTo(cost, 2), calibrated
+}
+
+// LeafTasks returns the tasks without subtasks; project level aggregations use these to avoid
+// counting rolled-up parents twice.
+func LeafTasks(tasks []models.Task) []models.Task {
+	parents := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		if t.ParentID != "" {
+			parents[t.ParentID] = true
+		}
+	}
+	leaves := make([]models.Task, 0, len(tasks))
+	for _, t := range tasks {
+		if !parents[t.ID] {
+			leaves = append(leaves, t)
+		}
+	}
+	return leaves
+}
+
+// BuildTaskTree nests tasks under their parents and returns the roots.
+// Tasks whose parent is not in the list are treated as roots. Siblings are ordered by priority, then creation time.
+func BuildTaskTree(tasks []models.Task) []models.Task {
+	byParent := make(map[string][]models.Task, len(tasks))
+	ids := make(map[string]bool, len(tasks))
+	for _, t := range tasks {
+		ids[t.ID] = true
+	}
+	for _, t := range tasks {
+		parent := t.ParentID
+		if !ids[parent] {
+			parent = ""
+		}
+		byParent[parent] = append(byParent[parent], t)
+	}
+	var build func(parent string, depth int) []models.Task
+	build = func(parent string, depth int) []models.Task {
+		nodes := byParent[parent]
+		sort.SliceStable(nodes, func(i, j int) bool {
+			if nodes[i].Priority != nodes[j].Priority {
+				return nodes[i].Priority < nodes[j].Priority
+			}
+			return nodes[i].CreatedAt.Before(nodes[j].CreatedAt)
+		})
+		if depth > maxTaskDepth {
+			return nodes
+		}
+		for i := range nodes {
+			nodes[i].Children = build(nodes[i].ID, depth+1)
+		}
+		return nodes
+	}
+	roots := build("", 0)
+	if roots == nil {
+		roots = []models.Task{}
+	}
+	return roots
+}
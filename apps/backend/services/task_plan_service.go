@@ -0,0 +1,330 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"neuro-dev/models"
+)
+
+var (
+	// ErrPreviewNotPending is returned when applying a preview that was already applied or discarded
+	ErrPreviewNotPending = errors.New("task plan preview is not pending")
+	// ErrTaskPlanConflict is returned when tasks changed between preview and apply
+	ErrTaskPlanConflict = errors.New("task plan conflict")
+)
+
+// taskMatchThreshold is the minimum similarity of name and requirements for two tasks to be considered the same
+const taskMatchThreshold = 0.5
+
+// PreviewTaskRegeneration generates a new task proposal for the project, diffs it against the
+// project's top-level budget tasks and stores the result as a pending preview.
+// Cost entries (expense_type=cost) and subtasks are never part of the plan.
+func (s *Service) PreviewTaskRegeneration(project *models.Project) (*models.TaskPlanPreview, error) {
+	proposal, err := s.GenerateTaskProposal(project)
+	if err != nil {
+		return nil, fmt.Errorf("generate task proposal failed: %w", err)
+	}
+	s.CalibrateTasks(proposal, project.Model)
+
+	existing := make([]models.Task, 0, len(project.Tasks))
+	for _, t := range project.Tasks {
+		if t.ExpenseType != "cost" && t.ParentID == "" {
+			existing = append(existing, t)
+		}
+	}
+
+	changes, unchanged := diffTaskPlans(existing, proposal)
+	preview := &models.TaskPlanPreview{
+		ID:        uuid.NewString(),
+		ProjectID: project.ID,
+		Status:    "pending",
+		Changes:   changes,
+		Unchanged: unchanged,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.DB.Create(preview).Error; err != nil {
+		return nil, fmt.Errorf("save task plan preview failed: %w", err)
+	}
+	return preview, nil
+}
+
+// ApplyTaskPlan applies the accepted changes of a pending preview.
+// Changed tasks keep their status, progress, current phase and results; only planning fields are updated.
+func (s *Service) ApplyTaskPlan(projectID, previewID string, accept []string, acceptAll bool) (*models.TaskPlanResult, error) {
+	result := &models.TaskPlanResult{PreviewID: previewID}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var preview models.TaskPlanPreview
+		if err := tx.First(&preview, "id = ? AND project_id = ?", previewID, projectID).Error; err != nil {
+			return err
+		}
+		if preview.Status != "pending" {
+			return ErrPreviewNotPending
+		}
+		var project models.Project
+		if err := tx.Select("id", "reporting_currency").First(&project, "id = ?", projectID).Error; err != nil {
+			return err
+		}
+
+		accepted := make(map[string]bool, len(accept))
+		for _, id := range accept {
+			accepted[id] = true
+		}
+		known := make(map[string]bool, len(preview.Changes))
+		for _, c := range preview.Changes {
+			known[c.ID] = true
+		}
+		for id := range accepted {
+			if !known[id] {
+				return fmt.Errorf("unknown change id: %s", id)
+			}
+		}
+
+		now := time.Now()
+		for _, change := range preview.Changes {
+			if !acceptAll && !accepted[change.ID] {
+				continue
+			}
+			switch change.Action {
+			case "added":
+				task := *change.Proposed
+				task.ID = s.NextTaskID()
+				task.ProjectID = projectID
+				task.Status = "pending"
+				task.ExpenseType = "budget"
+				task.Currency = project.ReportingCurrency
+				task.CreatedAt = now
+				task.UpdatedAt = now
+				if err := tx.Create(&task).Error; err != nil {
+					return err
+				}
+				result.Applied = append(result.Applied, models.TaskPlanEntry{Action: change.Action, After: &task})
+				result.Added++
+			case "removed":
+				current, err := currentPlannedTask(tx, change)
+				if err != nil {
+					return err
+				}
+				if current.Status == "in_progress" || current.Status == "running" {
+					return fmt.Errorf("%w: task %s is running and cannot be removed", ErrTaskPlanConflict, current.ID)
+				}
+				// Subtasks go with their parent
+				ids, err := s.DescendantTaskIDs(tx, current.ID)
+				if err != nil {
+					return err
+				}
+				var removed []models.Task
+				if err := tx.Where("id IN ?", append(ids, current.ID)).Find(&removed).Error; err != nil {
+					return err
+				}
+				if err := tx.Delete(&models.Task{}, "id IN ?", append(ids, current.ID)).Error; err != nil {
+					return err
+				}
+				for i := range removed {
+					result.Applied = append(result.Applied, models.TaskPlanEntry{Action: change.Action, Before: &removed[i]})
+				}
+				result.Removed++
+			case "changed":
+				before, err := currentPlannedTask(tx, change)
+				if err != nil {
+					return err
+				}
+				p := change.Proposed
+				if err := tx.Model(&models.Task{}).Where("id = ?", change.TaskID).Updates(map[string]interface{}{
+					"name":               p.Name,
+					"description":        p.Description,
+					"type":               p.Type,
+					"priority":           p.Priority,
+					"assigned_role":      p.AssignedRole,
+					"requirements":       p.Requirements,
+					"estimated_days":     p.EstimatedDays,
+					"estimated_cost":     p.EstimatedCost,
+					"estimate_model":     p.EstimateModel,
+					"calibrated_days":    p.CalibratedDays,
+					"calibrated_cost":    p.CalibratedCost,
+					"prompt_template_id": p.PromptTemplateID,
+					"prompt_version":     p.PromptVersion,
+					"updated_at":         now,
+				}).Error; err != nil {
+					return err
+				}
+				// A task with subtasks keeps the estimates rolled up from them
+				if err := s.RollupTaskAncestors(tx, change.TaskID); err != nil {
+					return err
+				}
+				var after models.Task
+				if err := tx.First(&after, "id = ?", change.TaskID).Error; err != nil {
+					return err
+				}
+				result.Applied = append(result.Applied, models.TaskPlanEntry{Action: change.Action, Before: before, After: &after})
+				result.Changed++
+			}
+		}
+
+		if err := tx.Model(&preview).Updates(map[string]interface{}{"status": "applied", "updated_at": now}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Project{}).Where("id = ?", projectID).Update("updated_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Where("project_id = ?", projectID).Find(&result.Tasks).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// currentPlannedTask loads the task a change refers to and fails if it was modified after the preview
+func currentPlannedTask(tx *gorm.DB, change models.TaskChange) (*models.Task, error) {
+	var current models.Task
+	if err := tx.First(&current, "id = ?", change.TaskID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: task %s no longer exists", ErrTaskPlanConflict, change.TaskID)
+		}
+		return nil, err
+	}
+	if change.Existing != nil && !current.UpdatedAt.Equal(change.Existing.UpdatedAt) {
+		return nil, fmt.Errorf("%w: task %s was modified after the preview", ErrTaskPlanConflict, change.TaskID)
+	}
+	return &current, nil
+}
+
+// diffTaskPlans matches proposed tasks to existing ones, first by exact name and then by the
+// similarity of name and requirements, and returns the changes plus the IDs of unchanged tasks.
+func diffTaskPlans(existing, proposed []models.Task) ([]models.TaskChange, []string) {
+	matchOf := make([]int, len(proposed))
+	used := make([]bool, len(existing))
+	for i := range matchOf {
+		matchOf[i] = -1
+	}
+
+	for i, p := range proposed {
+		for j, e := range existing {
+			if !used[j] && normalizeTaskText(p.Name) == normalizeTaskText(e.Name) {
+				matchOf[i], used[j] = j, true
+				break
+			}
+		}
+	}
+	for i, p := range proposed {
+		if matchOf[i] != -1 {
+			continue
+		}
+		best, bestScore := -1, 0.0
+		for j, e := range existing {
+			if used[j] {
+				continue
+			}
+			if score := textSimilarity(p.Name+p.Requirements, e.Name+e.Requirements); score > bestScore {
+				best, bestScore = j, score
+			}
+		}
+		if best != -1 && bestScore >= taskMatchThreshold {
+			matchOf[i], used[best] = best, true
+		}
+	}
+
+	changes := []models.TaskChange{}
+	unchanged := []string{}
+	nextID := func() string { return fmt.Sprintf("c%d", len(changes)+1) }
+	for i := range proposed {
+		p := planSnapshot(proposed[i])
+		if matchOf[i] == -1 {
+			changes = append(changes, models.TaskChange{ID: nextID(), Action: "added", Proposed: &p})
+			continue
+		}
+		e := planSnapshot(existing[matchOf[i]])
+		fields := changedTaskFields(e, p)
+		if len(fields) == 0 {
+			unchanged = append(unchanged, e.ID)
+			continue
+		}
+		changes = append(changes, models.TaskChange{ID: nextID(), Action: "changed", TaskID: e.ID, Fields: fields, Existing: &e, Proposed: &p})
+	}
+	for j := range existing {
+		if used[j] {
+			continue
+		}
+		e := planSnapshot(existing[j])
+		changes = append(changes, models.TaskChange{ID: nextID(), Action: "removed", TaskID: e.ID, Existing: &e})
+	}
+	return changes, unchanged
+}
+
+// planSnapshot drops execution results, which are irrelevant for planning and can be large
+func planSnapshot(t models.Task) models.Task {
+	t.Results = models.TaskResults{}
+	return t
+}
+
+func changedTaskFields(e, p models.Task) []string {
+	var fields []string
+	if e.Name != p.Name {
+		fields = append(fields, "name")
+	}
+	if e.Description != p.Description {
+		fields = append(fields, "description")
+	}
+	if e.Type != p.Type {
+		fields = append(fields, "type")
+	}
+	if e.Priority != p.Priority {
+		fields = append(fields, "priority")
+	}
+	if e.AssignedRole != p.AssignedRole {
+		fields = append(fields, "assigned_role")
+	}
+	if e.Requirements != p.Requirements {
+		fields = append(fields, "requirements")
+	}
+	if e.EstimatedDays != p.EstimatedDays {
+		fields = append(fields, "estimated_days")
+	}
+	if e.EstimatedCost != p.EstimatedCost {
+		fields = append(fields, "estimated_cost")
+	}
+	return fields
+}
+
+func normalizeTaskText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, s)
+}
+
+// textSimilarity is the Jaccard similarity of character bigrams, which works for Chinese and English text
+func textSimilarity(a, b string) float64 {
+	ga, gb := bigrams(normalizeTaskText(a)), bigrams(normalizeTaskText(b))
+	if len(ga) == 0 || len(gb) == 0 {
+		return 0
+	}
+	inter := 0
+	for g := range ga {
+		if gb[g] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(ga)+len(gb)-inter)
+}
+
+func bigrams(s string) map[string]bool {
+	runes := []rune(s)
+	grams := make(map[string]bool, len(runes))
+	if len(runes) == 1 {
+		grams[s] = true
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		grams[string(runes[i:i+2])] = true
+	}
+	return grams
+}
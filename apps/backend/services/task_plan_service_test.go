@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+
+	"neuro-dev/models"
+)
+
+func TestApplyTaskPlanReportsTaskStates(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	if err := s.DB.Create(&models.Project{ID: "p1"}).Error; err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+	tasks := []models.Task{
+		{ID: "t-old", ProjectID: "p1", Name: "old", Status: "pending"},
+		{ID: "t-old-sub", ProjectID: "p1", ParentID: "t-old", Name: "old subtask", Status: "pending"},
+		{ID: "t-edit", ProjectID: "p1", Name: "edit", Status: "pending"},
+	}
+	if err := s.DB.Create(&tasks).Error; err != nil {
+		t.Fatalf("create tasks: %v", err)
+	}
+	preview := models.TaskPlanPreview{ID: "pv1", ProjectID: "p1", Status: "pending", Changes: []models.TaskChange{
+		{ID: "c1", Action: "added", Proposed: &models.Task{Name: "new"}},
+		{ID: "c2", Action: "removed", TaskID: "t-old"},
+		{ID: "c3", Action: "changed", TaskID: "t-edit", Proposed: &models.Task{Name: "renamed"}},
+	}}
+	if err := s.DB.Create(&preview).Error; err != nil {
+		t.Fatalf("create preview: %v", err)
+	}
+
+	result, err := s.ApplyTaskPlan("p1", "pv1", nil, true)
+	if err != nil {
+		t.Fatalf("ApplyTaskPlan: %v", err)
+	}
+	got := map[string]models.TaskPlanEntry{}
+	for _, e := range result.Applied {
+		id := ""
+		if e.Before != nil {
+			id = e.Before.ID
+		} else if e.After != nil {
+			id = e.After.Name
+		}
+		got[id] = e
+	}
+	if len(result.Applied) != 4 {
+		t.Fatalf("applied %d entries, want 4: %+v", len(result.Applied), result.Applied)
+	}
+	if e := got["new"]; e.Action != "added" || e.Before != nil || e.After.ID == "" {
+		t.Errorf("added entry = %+v", e)
+	}
+	for _, id := range []string{"t-old", "t-old-sub"} {
+		if e := got[id]; e.Action != "removed" || e.After != nil {
+			t.Errorf("removed entry for %s = %+v", id, e)
+		}
+	}
+	if e := got["t-edit"]; e.Action != "changed" || e.Before.Name != "edit" || e.After.Name != "renamed" {
+		t.Errorf("changed entry = %+v", e)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/tmc/langchaingo/llms/openai"
+)
+
+// Structured output modes supported by models.Model.StructuredOutput
+const (
+	OutputModeText       = "text"        // plain prompt, JSON extracted from the reply
+	OutputModeJSONObject = "json_object" // provider JSON mode
+	OutputModeJSONSchema = "json_schema" // provider structured output with the task schema
+	OutputModeFunction   = "function"    // function calling with the task schema as parameters
+)
+
+// IsOutputMode reports whether mode is a supported structured output mode; empty means text
+func IsOutputMode(mode string) bool {
+	switch mode {
+	case "", OutputModeText, OutputModeJSONObject, OutputModeJSONSchema, OutputModeFunction:
+		return true
+	}
+	return false
+}
+
+// maxTaskRepairAttempts is how many times a failing reply is sent back to the model for repair
+const maxTaskRepairAttempts = 2
+
+const taskSchemaName = "submit_tasks"
+
+// GeneratedTaskTypes are the task types the model may assign to a generated task
+var GeneratedTaskTypes = []string{"前端web研发", "后端服务研发", "测试", "运维", "运营"}
+
+// generatedTask is the shape of a task in the model reply
+type generatedTask struct {
+	Name          string  `json:"name"`
+	Description   string  `json:"description"`
+	Type          string  `json:"type"`
+	Priority      int     `json:"priority"`
+	AssignedRole  string  `json:"assigned_role"`
+	Requirements  string  `json:"requirements"`
+	EstimatedDays int     `json:"estimated_days"`
+	EstimatedCost float64 `json:"estimated_cost"`
+}
+
+type generatedTaskList struct {
+	Tasks []generatedTask `json:"tasks"`
+}
+
+// taskListSchema describes the reply as {"tasks": [...]}.
+// JSON mode and structured output both require an object at the top level.
+func taskListSchema() *openai.ResponseFormatJSONSchemaProperty {
+	types := make([]interface{}, len(GeneratedTaskTypes))
+	for i, t := range GeneratedTaskTypes {
+		types[i] = t
+	}
+	task := &openai.ResponseFormatJSONSchemaProperty{
+		Type: "object",
+		Properties: map[string]*openai.ResponseFormatJSONSchemaProperty{
+			"name":           {Type: "string", Description: "任务名称"},
+			"description":    {Type: "string", Description: "详细描述"},
+			"type":           {Type: "string", Description: "任务类型", Enum: types},
+			"priority":       {Type: "integer", Description: "优先级，1最高", Enum: []interface{}{1, 2, 3}},
+			"assigned_role":  {Type: "string", Description: "负责角色"},
+			"requirements":   {Type: "string", Description: "具体要实现的功能"},
+			"estimated_days": {Type: "integer", Description: "预计研发天数"},
+			"estimated_cost": {Type: "number", Description: "预计研发费用"},
+		},
+		Required:             []string{"name", "description", "type", "priority", "assigned_role", "requirements", "estimated_days", "estimated_cost"},
+		AdditionalProperties: false,
+	}
+	return &openai.ResponseFormatJSONSchemaProperty{
+		Type: "object",
+		Properties: map[string]*openai.ResponseFormatJSONSchemaProperty{
+			"tasks": {Type: "array", Items: task},
+		},
+		Required:             []string{"tasks"},
+		AdditionalProperties: false,
+	}
+}
+
+// taskListResponseFormat is the structured output format used in json_schema mode
+func taskListResponseFormat() *openai.ResponseFormat {
+	return &openai.ResponseFormat{
+		Type: "json_schema",
+		JSONSchema: &openai.ResponseFormatJSONSchema{
+			Name:   taskSchemaName,
+			Strict: true,
+			Schema: taskListSchema(),
+		},
+	}
+}
+
+// decodeGeneratedTasks parses and validates a model reply.
+// It accepts {"tasks": [...]} or a bare array, optionally wrapped in prose or a code fence.
+// All validation problems are returned together so they can be sent back in one repair prompt.
+func decodeGeneratedTasks(reply string) ([]generatedTask, []string) {
+	raw := extractJSON(reply)
+	if raw == "" {
+		return nil, []string{"reply does not contain a JSON object or array"}
+	}
+
+	var tasks []generatedTask
+	if strings.HasPrefix(raw, "[") {
+		if err := json.Unmarshal([]byte(raw), &tasks); err != nil {
+			return nil, []string{"invalid JSON: " + err.Error()}
+		}
+	} else {
+		var list generatedTaskList
+		if err := json.Unmarshal([]byte(raw), &list); err != nil {
+			return nil, []string{"invalid JSON: " + err.Error()}
+		}
+		tasks = list.Tasks
+	}
+	if errs := validateGeneratedTasks(tasks); len(errs) > 0 {
+		return nil, errs
+	}
+	return tasks, nil
+}
+
+func validateGeneratedTasks(tasks []generatedTask) []string {
+	if len(tasks) == 0 {
+		return []string{"tasks must contain at least one task"}
+	}
+	var errs []string
+	for i, t := range tasks {
+		field := func(name string) string { return fmt.Sprintf("tasks[%d].%s", i, name) }
+		if strings.TrimSpace(t.Name) == "" {
+			errs = append(errs, field("name")+" is required")
+		}
+		if strings.TrimSpace(t.Description) == "" {
+			errs = append(errs, field("description")+" is required")
+		}
+		if !isGeneratedTaskType(t.Type) {
+			errs = append(errs, fmt.Sprintf("%s must be one of %s, got %q", field("type"), strings.Join(GeneratedTaskTypes, ", "), t.Type))
+		}
+		if t.Priority < 1 || t.Priority > 3 {
+			errs = append(errs, fmt.Sprintf("%s must be between 1 and 3, got %d", field("priority"), t.Priority))
+		}
+		if t.EstimatedDays <= 0 {
+			errs = append(errs, fmt.Sprintf("%s must be a positive integer, got %d", field("estimated_days"), t.EstimatedDays))
+		}
+		if t.EstimatedCost < 0 {
+			errs = append(errs, fmt.Sprintf("%s must not be negative, got %v", field("estimated_cost"), t.EstimatedCost))
+		}
+	}
+	return errs
+}
+
+func isGeneratedTaskType(t string) bool {
+	for _, allowed := range GeneratedTaskTypes {
+		if t == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// extractJSON returns the outermost JSON object or array of a reply, whichever starts first
+func extractJSON(reply string) string {
+	start := strings.IndexAny(reply, "{[")
+	if start == -1 {
+		return ""
+	}
+	closing := "}"
+	if reply[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(reply, closing)
+	if end <= start {
+		return ""
+	}
+	return reply[start : end+1]
+}
+
+// taskRepairPrompt asks the model to fix a reply that failed validation
+func taskRepairPrompt(locale string, errs []string) string {
+	if locale == LocaleEn {
+		return fmt.Sprintf(`The task list you returned last time failed validation with the following errors:
+- %s
+
+Fix all of the problems above and return the complete task list again. Return only JSON in the form {"tasks": [...]}, without any other text.`, strings.Join(errs, "\n- "))
+	}
+	return fmt.Sprintf(`你上一次返回的任务列表没有通过校验，错误如下：
+- %s
+
+请修正以上所有问题后重新返回完整的任务列表。只返回JSON，格式为 {"tasks": [...]}，不要包含任何其他文字。`, strings.Join(errs, "\n- "))
+}
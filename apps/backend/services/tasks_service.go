@@ -1,52 +1,98 @@
 package services
 
 import (
-	"fmt"
-	"log"
+	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"neuro-dev/logging"
+	"neuro-dev/metrics"
 	"neuro-dev/models"
 )
 
 // Task-related service methods
-func (s *Service) GenerateTasksFromDescription(description string, model string, vendors string) []models.Task {
-	prompt := fmt.Sprintf(`作为一个资深的软件架构师，请参考以下云厂商的功能：
-云厂商：%s
-将以下项目描述分解成具体的开发任务。每个任务应该包含：任务名称、详细描述、类型（前端web研发/后端服务研发/测试/运维/运营）、优先级（1-3）、具体要求、预计研发天数、预计研发费用。
-项目描述：%s
 
-请以JSON格式返回任务列表，格式如下：
-[
-  {
-    "name": "任务名称",
-    "description": "详细描述",
-    "type": "研发",
-    "priority": 1,
-    "requirements": "具体要实现的功能",
-    "estimated_days": 5,
-    "estimated_cost": 12000
-
-  }
-]
+// GenerateTasksFromDescription decomposes a project description into tasks.
+// When generation fails a single placeholder task describing the error is returned.
+func (s *Service) GenerateTasksFromDescription(project *models.Project) []models.Task {
+	tasks, err := s.GenerateTaskProposal(project)
+	if err != nil || len(tasks) == 0 {
+		desc := ""
+		if err != nil {
+			desc = err.Error()
+		}
+		return s.getFallbackTasks(desc, project.Locale)
+	}
+	return tasks
+}
 
-请生成任务列表，按优先级排序。`, description, vendors)
+// GenerateTaskProposal decomposes a project description into tasks and reports generation failures
+func (s *Service) GenerateTaskProposal(project *models.Project) ([]models.Task, error) {
+	tmpl, err := s.ActivePrompt(models.PromptKindTaskGeneration, "", project.Locale)
+	if err != nil {
+		return nil, err
+	}
+	prompt := RenderPrompt(tmpl.Content, map[string]string{
+		"vendors":     project.Vendors,
+		"task_types":  strings.Join(GeneratedTaskTypes, "/"),
+		"description": project.Description,
+		"format":      taskListFormats[tmpl.Locale],
+	})
+	return s.callLLMAPI(promptRequest(project.ID, PhaseGenerateTasks, tmpl, prompt), project.Model)
+}
 
-	tasks := s.callLLMAPI(prompt, model)
-	if len(tasks) == 0 {
-		return s.getFallbackTasks("")
+// DecomposeTask asks the project's model to break one task into subtasks and returns them unsaved
+func (s *Service) DecomposeTask(task *models.Task, project *models.Project) ([]models.Task, error) {
+	tmpl, err := s.ActivePrompt(models.PromptKindTaskDecomposition, "", project.Locale)
+	if err != nil {
+		return nil, err
 	}
-	return tasks
+	prompt := RenderPrompt(tmpl.Content, map[string]string{
+		"task_types":     strings.Join(GeneratedTaskTypes, "/"),
+		"name":           task.Name,
+		"description":    task.Description,
+		"requirements":   task.Requirements,
+		"estimated_days": strconv.Itoa(task.EstimatedDays),
+		"estimated_cost": strconv.FormatFloat(task.EstimatedCost, 'f', -1, 64),
+		"format":         taskListFormats[tmpl.Locale],
+	})
+	return s.callLLMAPI(promptRequest(task.ProjectID, PhaseDecomposeTask, tmpl, prompt), project.Model)
+}
+
+// CreateSubtasks stores subtasks below parent and rolls their estimates up the hierarchy
+func (s *Service) CreateSubtasks(parent *models.Task, subtasks []models.Task) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		for i := range subtasks {
+			t := &subtasks[i]
+			if t.ID == "" {
+				t.ID = s.NextTaskID()
+			}
+			t.ProjectID = parent.ProjectID
+			t.ParentID = parent.ID
+			t.Currency = parent.Currency
+			t.ExpenseType = parent.ExpenseType
+			t.Status = "pending"
+			t.CreatedAt = now
+			t.UpdatedAt = now
+			if err := tx.Create(t).Error; err != nil {
+				return err
+			}
+		}
+		return s.RollupTaskAncestors(tx, parent.ID)
+	})
 }
 
 func (s *Service) NextTaskID() string {
 	return uuid.NewString()
 }
 
+// ExecuteTask runs the task's phases and checkpoints after each one. A task that was interrupted resumes
+// after its last finished phase; on shutdown it stops after the current phase and goes back to pending.
 func (s *Service) ExecuteTask(task *models.Task, project *models.Project) {
-	task.Status = "in_progress"
-	task.UpdatedAt = time.Now()
-
 	phases := []string{
 		"DemandAnalysis",
 		"LanguageChoose",
@@ -59,18 +105,59 @@ func (s *Service) ExecuteTask(task *models.Task, project *models.Project) {
 		"TestErrorSummary",
 		"TestModification",
 	}
-
+	ctx := logging.With(s.Context(), "project_id", project.ID, "task_id", task.ID)
+	start := 0
 	for i, phase := range phases {
+		if task.CurrentPhase == phase {
+			start = i + 1
+			slog.InfoContext(ctx, "Resuming task", "after_phase", phase)
+		}
+	}
+
+	task.Status = "in_progress"
+	task.UpdatedAt = time.Now()
+	if task.StartedAt == nil {
+		started := task.UpdatedAt
+		task.StartedAt = &started
+	}
+	s.checkpointTask(ctx, task)
+
+	for i := start; i < len(phases); i++ {
+		if s.exec.ctx.Err() != nil {
+			task.Status = "pending"
+			task.UpdatedAt = time.Now()
+			s.checkpointTask(ctx, task)
+			slog.InfoContext(ctx, "Stopped task for shutdown", "phase", task.CurrentPhase, "progress", task.Progress)
+			return
+		}
+		phase := phases[i]
+		started := time.Now()
+		transcript, err := s.phaseTranscript(task, project, phase)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load phase prompts", "phase", phase, "error", err)
+		}
+		time.Sleep(1 * time.Second)
+		metrics.PhaseDuration.WithLabelValues(phase).Observe(time.Since(started).Seconds())
+		if transcript != nil {
+			if err := s.DB.Create(transcript).Error; err != nil {
+				slog.ErrorContext(ctx, "Failed to record phase transcript", "phase", phase, "error", err)
+			}
+		}
 		task.CurrentPhase = phase
 		task.Progress = int((float64(i+1) / float64(len(phases))) * 100)
 		task.UpdatedAt = time.Now()
-		time.Sleep(1 * time.Second)
-		log.Printf("Task %s in Project %s: Completed phase %s (%d%%)", task.ID, project.ID, phase, task.Progress)
+		s.checkpointTask(ctx, task)
+		slog.InfoContext(ctx, "Completed phase", "phase", phase, "progress", task.Progress)
 	}
 
+	// The phases are simulated, so their timing says nothing about the estimate; actuals are only
+	// recorded by CompleteTask and calibration ignores the task until then
+	now := time.Now()
 	task.Status = "completed"
 	task.Progress = 100
 	task.CurrentPhase = "finished"
-	task.UpdatedAt = time.Now()
-	log.Printf("Task %s in Project %s completed successfully", task.ID, project.ID)
+	task.CompletedAt = &now
+	task.UpdatedAt = now
+	s.checkpointTask(ctx, task)
+	slog.InfoContext(ctx, "Task completed")
 }
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"neuro-dev/models"
+)
+
+const fakeTaskReply = `{"tasks": [
+	{"name": "Login API", "description": "Password login", "type": "后端服务研发", "priority": 1,
+	 "assigned_role": "Programmer", "requirements": "JWT tokens", "estimated_days": 3, "estimated_cost": 3000},
+	{"name": "Login page", "description": "Login form", "type": "前端web研发", "priority": 2,
+	 "assigned_role": "Programmer", "requirements": "Validation", "estimated_days": 2, "estimated_cost": 2000}
+]}`
+
+func TestGenerateTaskProposalOffline(t *testing.T) {
+	fake := NewFakeLLM().On(PhaseGenerateTasks, "", fakeTaskReply)
+	s := newTestService(t, fake)
+	project := &models.Project{ID: "p1", Model: "not-configured", Description: "A login feature", Locale: "en"}
+
+	tasks, err := s.GenerateTaskProposal(project)
+	if err != nil {
+		t.Fatalf("GenerateTaskProposal: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(tasks))
+	}
+	if tasks[0].Name != "Login API" || tasks[0].EstimatedDays != 3 || tasks[0].Status != "pending" {
+		t.Errorf("unexpected first task: %+v", tasks[0])
+	}
+	calls := fake.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("got %d LLM calls, want 1", len(calls))
+	}
+	if !calls[0].Structured || !strings.Contains(calls[0].Messages[0].Content, "A login feature") {
+		t.Errorf("unexpected request: %+v", calls[0])
+	}
+}
+
+func TestGenerateTaskProposalRepairsInvalidReply(t *testing.T) {
+	fake := NewFakeLLM().On(PhaseGenerateTasks, "", `{"tasks": []}`, fakeTaskReply)
+	s := newTestService(t, fake)
+	project := &models.Project{ID: "p1", Model: "not-configured", Description: "A login feature", Locale: "en"}
+
+	tasks, err := s.GenerateTaskProposal(project)
+	if err != nil {
+		t.Fatalf("GenerateTaskProposal: %v", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("got %d tasks, want 2", len(tasks))
+	}
+	calls := fake.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("got %d LLM calls, want 2", len(calls))
+	}
+	if n := len(calls[1].Messages); n != 3 {
+		t.Errorf("repair request has %d messages, want 3", n)
+	}
+}
@@ -0,0 +1,285 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"neuro-dev/models"
+)
+
+// encryptedTokenPrefix marks tokens stored as envelopes; anything else is a legacy plain text token
+const encryptedTokenPrefix = "enc:v1:"
+
+var (
+	// ErrTokenKeyMissing is returned when tokens must be encrypted but no key is configured
+	ErrTokenKeyMissing = errors.New("token encryption key is not configured")
+	// ErrUnknownTokenKey is returned for envelopes wrapped with a key that is neither current nor previous
+	ErrUnknownTokenKey = errors.New("token was encrypted with an unknown key")
+)
+
+// TokenCipher encrypts model tokens with envelope encryption: every token gets its own random data key,
+// the token is sealed with the data key and the data key is sealed with the master key (AES-256-GCM).
+// Rotating the master key only re-wraps data keys. Previous keys are kept for decryption only.
+type TokenCipher struct {
+	current  string                 // ID of the master key used for new envelopes
+	keys     map[string]cipher.AEAD // master keys by ID
+	disabled bool
+}
+
+// NewTokenCipher builds a cipher from base64 encoded 32 byte master keys.
+// An empty current key disables encryption: tokens are stored as given, which is only meant for local development
+// and rejected by config validation in prod mode.
+func NewTokenCipher(currentKey string, previousKeys []string) (*TokenCipher, error) {
+	c := &TokenCipher{keys: make(map[string]cipher.AEAD)}
+	if strings.TrimSpace(currentKey) == "" {
+		c.disabled = true
+	} else {
+		id, aead, err := parseTokenKey(currentKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token key: %w", err)
+		}
+		c.current, c.keys[id] = id, aead
+	}
+	for i, k := range previousKeys {
+		id, aead, err := parseTokenKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous token key %d: %w", i+1, err)
+		}
+		if _, ok := c.keys[id]; !ok {
+			c.keys[id] = aead
+		}
+	}
+	return c, nil
+}
+
+func parseTokenKey(encoded string) (string, cipher.AEAD, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(key) != 32 {
+		return "", nil, fmt.Errorf("key must be 32 bytes, got %d", len(key))
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return "", nil, err
+	}
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4]), aead, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Enabled reports whether a master key is configured
+func (c *TokenCipher) Enabled() bool {
+	return c != nil && !c.disabled
+}
+
+// IsEncryptedToken reports whether a stored token is an envelope
+func IsEncryptedToken(stored string) bool {
+	return strings.HasPrefix(stored, encryptedTokenPrefix)
+}
+
+// Encrypt seals a plain token as enc:v1:<key id>:<wrapped data key>:<sealed token>
+func (c *TokenCipher) Encrypt(plain string) (string, error) {
+	if plain == "" || IsEncryptedToken(plain) {
+		return plain, nil
+	}
+	if !c.Enabled() {
+		return plain, nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(c.keys[c.current], dek)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(aead, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return encryptedTokenPrefix + c.current + ":" + wrapped + ":" + sealed, nil
+}
+
+// Decrypt opens an envelope; plain text tokens from before encryption are returned unchanged
+func (c *TokenCipher) Decrypt(stored string) (string, error) {
+	if !IsEncryptedToken(stored) {
+		return stored, nil
+	}
+	dek, sealed, err := c.openDataKey(stored)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newGCM(dek)
+	if err != nil {
+		return "", err
+	}
+	plain, err := open(aead, sealed)
+	if err != nil {
+		return "", fmt.Errorf("decrypt token failed: %w", err)
+	}
+	return string(plain), nil
+}
+
+// Rewrap encrypts plain text tokens and re-wraps envelopes of previous keys with the current key.
+// It reports whether the stored value changed.
+func (c *TokenCipher) Rewrap(stored string) (string, bool, error) {
+	if !c.Enabled() {
+		return stored, false, ErrTokenKeyMissing
+	}
+	if stored == "" {
+		return stored, false, nil
+	}
+	if !IsEncryptedToken(stored) {
+		enc, err := c.Encrypt(stored)
+		return enc, err == nil, err
+	}
+	if strings.HasPrefix(stored, encryptedTokenPrefix+c.current+":") {
+		return stored, false, nil
+	}
+	dek, sealed, err := c.openDataKey(stored)
+	if err != nil {
+		return stored, false, err
+	}
+	wrapped, err := seal(c.keys[c.current], dek)
+	if err != nil {
+		return stored, false, err
+	}
+	return encryptedTokenPrefix + c.current + ":" + wrapped + ":" + sealed, true, nil
+}
+
+// openDataKey unwraps the data key of an envelope and returns it with the sealed token
+func (c *TokenCipher) openDataKey(stored string) ([]byte, string, error) {
+	parts := strings.Split(strings.TrimPrefix(stored, encryptedTokenPrefix), ":")
+	if len(parts) != 3 {
+		return nil, "", errors.New("malformed encrypted token")
+	}
+	var kek cipher.AEAD
+	if c != nil {
+		kek = c.keys[parts[0]]
+	}
+	if kek == nil {
+		return nil, "", fmt.Errorf("%w: %s", ErrUnknownTokenKey, parts[0])
+	}
+	dek, err := open(kek, parts[1])
+	if err != nil {
+		return nil, "", fmt.Errorf("unwrap token key failed: %w", err)
+	}
+	return dek, parts[2], nil
+}
+
+// seal encrypts with a random nonce and returns base64(nonce|ciphertext)
+func seal(aead cipher.AEAD, plain []byte) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawStdEncoding.EncodeToString(aead.Seal(nonce, nonce, plain, nil)), nil
+}
+
+func open(aead cipher.AEAD, encoded string) ([]byte, error) {
+	b, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return aead.Open(nil, b[:aead.NonceSize()], b[aead.NonceSize():], nil)
+}
+
+// MaskToken returns a preview that is safe to show: the first three and last four characters
+func MaskToken(plain string) string {
+	if plain == "" {
+		return ""
+	}
+	runes := []rune(plain)
+	if len(runes) <= 10 {
+		return "****"
+	}
+	return string(runes[:3]) + "****" + string(runes[len(runes)-4:])
+}
+
+// SetModelToken encrypts a new token into the model and refreshes its preview
+func (s *Service) SetModelToken(m *models.Model, plain string) error {
+	enc, err := s.Tokens.Encrypt(plain)
+	if err != nil {
+		return fmt.Errorf("encrypt token failed: %w", err)
+	}
+	m.Token = enc
+	m.TokenPreview = MaskToken(plain)
+	return nil
+}
+
+// MaskModelTokens fills the token previews of models loaded from the database for API responses
+func (s *Service) MaskModelTokens(list ...*models.Model) {
+	for _, m := range list {
+		plain, err := s.Tokens.Decrypt(m.Token)
+		if err != nil {
+			m.TokenPreview = "****"
+			continue
+		}
+		m.TokenPreview = MaskToken(plain)
+	}
+}
+
+// decryptModel returns a copy of m with the plain token, for LLM clients
+func (s *Service) decryptModel(m *models.Model) (*models.Model, error) {
+	plain, err := s.Tokens.Decrypt(m.Token)
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", m.Name, err)
+	}
+	decrypted := *m
+	decrypted.Token = plain
+	return &decrypted, nil
+}
+
+// EncryptModelTokens migrates stored tokens to the current master key: plain text tokens are
+// encrypted and envelopes of previous keys are re-wrapped. It returns the number of updated models.
+func (s *Service) EncryptModelTokens() (int, error) {
+	if !s.Tokens.Enabled() {
+		return 0, ErrTokenKeyMissing
+	}
+	var list []models.Model
+	if err := s.DB.Unscoped().Where("token <> ''").Find(&list).Error; err != nil {
+		return 0, err
+	}
+	updated := 0
+	for _, m := range list {
+		token, changed, err := s.Tokens.Rewrap(m.Token)
+		if err != nil {
+			return updated, fmt.Errorf("model %s: %w", m.Name, err)
+		}
+		if !changed {
+			continue
+		}
+		if err := s.DB.Unscoped().Model(&models.Model{}).Where("id = ?", m.ID).Update("token", token).Error; err != nil {
+			return updated, err
+		}
+		updated++
+	}
+	if updated > 0 {
+		slog.Info("Encrypted model tokens", "count", updated, "key", s.Tokens.current)
+	}
+	return updated, nil
+}
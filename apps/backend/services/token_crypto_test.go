@@ -0,0 +1,125 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTokenKey(t *testing.T) string {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func newTestCipher(t *testing.T, current string, previous ...string) *TokenCipher {
+	t.Helper()
+	c, err := NewTokenCipher(current, previous)
+	if err != nil {
+		t.Fatalf("NewTokenCipher: %v", err)
+	}
+	return c
+}
+
+func TestTokenCipherRoundTrip(t *testing.T) {
+	c := newTestCipher(t, newTokenKey(t))
+	enc, err := c.Encrypt("sk-secret-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if !IsEncryptedToken(enc) || strings.Contains(enc, "sk-secret-token") {
+		t.Fatalf("token not encrypted: %s", enc)
+	}
+	again, err := c.Encrypt("sk-secret-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if again == enc {
+		t.Error("encrypting twice produced the same envelope")
+	}
+	plain, err := c.Decrypt(enc)
+	if err != nil || plain != "sk-secret-token" {
+		t.Fatalf("Decrypt = %q, %v", plain, err)
+	}
+	if plain, err := c.Decrypt("legacy-plain"); err != nil || plain != "legacy-plain" {
+		t.Errorf("legacy token: got %q, %v", plain, err)
+	}
+}
+
+func TestTokenCipherRotation(t *testing.T) {
+	oldKey, newKey := newTokenKey(t), newTokenKey(t)
+	enc, err := newTestCipher(t, oldKey).Encrypt("sk-secret-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+
+	rotated := newTestCipher(t, newKey, oldKey)
+	if plain, err := rotated.Decrypt(enc); err != nil || plain != "sk-secret-token" {
+		t.Fatalf("decrypt with previous key = %q, %v", plain, err)
+	}
+	rewrapped, changed, err := rotated.Rewrap(enc)
+	if err != nil || !changed {
+		t.Fatalf("Rewrap = %v, %v", changed, err)
+	}
+	if _, changed, _ := rotated.Rewrap(rewrapped); changed {
+		t.Error("rewrapping a current envelope changed it")
+	}
+
+	newOnly := newTestCipher(t, newKey)
+	if plain, err := newOnly.Decrypt(rewrapped); err != nil || plain != "sk-secret-token" {
+		t.Fatalf("decrypt rewrapped = %q, %v", plain, err)
+	}
+	if _, err := newOnly.Decrypt(enc); !errors.Is(err, ErrUnknownTokenKey) {
+		t.Errorf("decrypt with retired key: got %v, want ErrUnknownTokenKey", err)
+	}
+}
+
+func TestTokenCipherTamper(t *testing.T) {
+	c := newTestCipher(t, newTokenKey(t))
+	enc, err := c.Encrypt("sk-secret-token")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	parts := strings.Split(strings.TrimPrefix(enc, encryptedTokenPrefix), ":")
+	flip := func(s string) string {
+		b := []byte(s)
+		if b[len(b)/2] == 'A' {
+			b[len(b)/2] = 'B'
+		} else {
+			b[len(b)/2] = 'A'
+		}
+		return string(b)
+	}
+	cases := map[string]string{
+		"data key": encryptedTokenPrefix + parts[0] + ":" + flip(parts[1]) + ":" + parts[2],
+		"token":    encryptedTokenPrefix + parts[0] + ":" + parts[1] + ":" + flip(parts[2]),
+		"swapped":  encryptedTokenPrefix + parts[0] + ":" + parts[2] + ":" + parts[1],
+		"missing":  encryptedTokenPrefix + parts[0] + ":" + parts[1],
+	}
+	for name, stored := range cases {
+		if _, err := c.Decrypt(stored); err == nil {
+			t.Errorf("%s: tampered envelope decrypted", name)
+		}
+	}
+}
+
+func TestTokenCipherDisabled(t *testing.T) {
+	c := newTestCipher(t, "")
+	if c.Enabled() {
+		t.Fatal("cipher without key is enabled")
+	}
+	if enc, err := c.Encrypt("sk-secret-token"); err != nil || enc != "sk-secret-token" {
+		t.Errorf("Encrypt = %q, %v", enc, err)
+	}
+	if _, _, err := c.Rewrap("sk-secret-token"); !errors.Is(err, ErrTokenKeyMissing) {
+		t.Errorf("Rewrap: got %v, want ErrTokenKeyMissing", err)
+	}
+	if _, err := NewTokenCipher("c2hvcnQ=", nil); err == nil {
+		t.Error("accepted a short key")
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"neuro-dev/models"
+)
+
+// phaseTranscript renders the active prompts of a phase and of the task's role. A phase or role without
+// an active template is run without that prompt and recorded with template ID 0.
+func (s *Service) phaseTranscript(task *models.Task, project *models.Project, phase string) (*models.TaskTranscript, error) {
+	vars := map[string]string{
+		"task":           task.Description,
+		"language":       task.Language,
+		"assistant_role": task.AssignedRole,
+		"chatdev_prompt": project.Description,
+	}
+	entry := &models.TaskTranscript{
+		TaskID:    task.ID,
+		ProjectID: task.ProjectID,
+		Phase:     phase,
+		Role:      task.AssignedRole,
+		CreatedAt: time.Now(),
+	}
+	tmpl, err := s.ActivePrompt(models.PromptKindPhase, phase, project.Locale)
+	if err != nil && !errors.Is(err, ErrNoActivePrompt) {
+		return nil, err
+	}
+	if tmpl != nil {
+		entry.Prompt = RenderPrompt(tmpl.Content, vars)
+		entry.PromptTemplateID, entry.PromptVersion = tmpl.ID, tmpl.Version
+	}
+	if task.AssignedRole == "" {
+		return entry, nil
+	}
+	role, err := s.ActivePrompt(models.PromptKindRole, task.AssignedRole, project.Locale)
+	if err != nil && !errors.Is(err, ErrNoActivePrompt) {
+		return nil, err
+	}
+	if role != nil {
+		entry.RolePrompt = RenderPrompt(role.Content, vars)
+		entry.RoleTemplateID, entry.RoleVersion = role.ID, role.Version
+	}
+	return entry, nil
+}
+
+// TaskTranscripts returns the executed phases of a task in execution order
+func (s *Service) TaskTranscripts(taskID string) ([]models.TaskTranscript, error) {
+	transcripts := []models.TaskTranscript{}
+	if err := s.DB.Where("task_id = ?", taskID).Order("created_at, id").Find(&transcripts).Error; err != nil {
+		return nil, err
+	}
+	return transcripts, nil
+}
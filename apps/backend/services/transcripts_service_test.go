@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+
+	"neuro-dev/models"
+)
+
+func TestPhaseTranscriptUsesActiveTemplates(t *testing.T) {
+	s := newTestService(t, NewFakeLLM())
+	create := func(kind, key, content string) *models.PromptTemplate {
+		tmpl, err := s.CreatePromptVersion(models.CreatePromptTemplateRequest{Kind: kind, Key: key, Locale: "en", Content: content, Activate: true})
+		if err != nil {
+			t.Fatalf("CreatePromptVersion: %v", err)
+		}
+		return tmpl
+	}
+	create(models.PromptKindPhase, "Coding", "v1 {task}")
+	phase := create(models.PromptKindPhase, "Coding", "v2 write {language} for {task}")
+	role := create(models.PromptKindRole, "Programmer", "You are a {assistant_role}")
+
+	task := &models.Task{ID: "t1", ProjectID: "p1", Description: "login", Language: "Go", AssignedRole: "Programmer"}
+	project := &models.Project{ID: "p1", Locale: "en"}
+	entry, err := s.phaseTranscript(task, project, "Coding")
+	if err != nil {
+		t.Fatalf("phaseTranscript: %v", err)
+	}
+	if entry.Prompt != "v2 write Go for login" || entry.PromptTemplateID != phase.ID || entry.PromptVersion != phase.Version {
+		t.Errorf("unexpected phase prompt: %+v", entry)
+	}
+	if entry.RolePrompt != "You are a Programmer" || entry.RoleTemplateID != role.ID || entry.RoleVersion != role.Version {
+		t.Errorf("unexpected role prompt: %+v", entry)
+	}
+
+	entry, err = s.phaseTranscript(task, project, "ArtDesign")
+	if err != nil {
+		t.Fatalf("phaseTranscript: %v", err)
+	}
+	if entry.Prompt != "" || entry.PromptTemplateID != 0 {
+		t.Errorf("phase without a template got %+v", entry)
+	}
+}
@@ -5,6 +5,7 @@ import {
   ProjectOutlined, 
   FileTextOutlined, 
   SettingOutlined,
+  LogoutOutlined,
   ThunderboltOutlined,
   UnorderedListOutlined 
 } from '@ant-design/icons';
@@ -17,6 +18,8 @@ import ProjectCreate from './components/ProjectCreate.tsx';
 import ProjectEdit from './components/ProjectEdit.tsx';
 import TaskCostCreate from './components/TaskCostCreate.tsx';
 import BillingList from './components/BillingList.tsx';
+import Login from './components/Login.tsx';
+import { authStore } from './utils/apiClient';
 import './App.css';
 
 const { useState, useEffect } = React;
@@ -135,6 +138,17 @@ function AppLayout() {
                 AI驱动的成本治理平台
               </Title>
               <Space>
+                <Button
+                  type="text"
+                  icon={<LogoutOutlined />}
+                  style={{ color: 'white' }}
+                  onClick={() => {
+                    authStore.clear();
+                    window.location.assign('/login');
+                  }}
+                >
+                  退出登录
+                </Button>
               </Space>
             </div>
           </Header>
@@ -162,10 +176,21 @@ function AppLayout() {
   );
 }
 
+// RequireAuth sends visitors without a token to the login page
+function RequireAuth({ children }) {
+  if (!authStore.getAccessToken()) {
+    return <Navigate to="/login" replace />;
+  }
+  return children;
+}
+
 function App() {
   return (
     <Router>
-      <AppLayout />
+      <Routes>
+        <Route path="/login" element={<Login />} />
+        <Route path="/*" element={<RequireAuth><AppLayout /></RequireAuth>} />
+      </Routes>
     </Router>
   );
 }
@@ -0,0 +1,54 @@
+import * as React from 'react';
+import { useNavigate } from 'react-router-dom';
+import { Card, Form, Input, Button, Typography, message } from 'antd';
+import { UserOutlined, LockOutlined } from '@ant-design/icons';
+import api, { authStore } from '../utils/apiClient';
+const { useState } = React;
+
+const { Title } = Typography;
+
+interface LoginValues {
+  username: string;
+  password: string;
+}
+
+function Login() {
+  const navigate = useNavigate();
+  const [loading, setLoading] = useState(false);
+
+  const onFinish = async (values: LoginValues) => {
+    setLoading(true);
+    try {
+      const res = await api.post('/api/auth/login', values);
+      if (res.ok && res.data?.success) {
+        authStore.save(res.data.data);
+        navigate('/projects', { replace: true });
+      } else {
+        message.error(res.data?.error || '登录失败');
+      }
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div style={{ minHeight: '100vh', display: 'flex', alignItems: 'center', justifyContent: 'center', background: '#001529' }}>
+      <Card style={{ width: 360 }}>
+        <Title level={3} style={{ textAlign: 'center' }}>登录</Title>
+        <Form layout="vertical" onFinish={onFinish}>
+          <Form.Item name="username" rules={[{ required: true, message: '请输入用户名' }]}>
+            <Input prefix={<UserOutlined />} placeholder="用户名" autoComplete="username" />
+          </Form.Item>
+          <Form.Item name="password" rules={[{ required: true, message: '请输入密码' }]}>
+            <Input.Password prefix={<LockOutlined />} placeholder="密码" autoComplete="current-password" />
+          </Form.Item>
+          <Button type="primary" htmlType="submit" loading={loading} block>
+            登录
+          </Button>
+        </Form>
+      </Card>
+    </div>
+  );
+}
+
+export default Login;
@@ -26,6 +26,7 @@ interface CreateProjectValues {
   description: string;
   vendors: string[];
   model: string;
+  organization_id: number;
 }
 
 function ProjectCreate() {
@@ -33,11 +34,24 @@ function ProjectCreate() {
   const navigate = useNavigate();
   const [loading, setLoading] = useState(false);
   const [models, setModels] = useState<any[]>([]);
+  const [organizations, setOrganizations] = useState<any[]>([]);
 
   useEffect(() => {
     loadModels();
+    loadOrganizations();
   }, []);
 
+  const loadOrganizations = async () => {
+    const response = await api.get('/api/organizations');
+    if ((response as any).data?.success) {
+      const orgs = ((response as any).data.data || []).filter((o: any) => !o.role || o.role !== 'viewer');
+      setOrganizations(orgs);
+      if (orgs.length === 1) {
+        form.setFieldsValue({ organization_id: orgs[0].id });
+      }
+    }
+  };
+
   const loadModels = async () => {
     try {
       const response = await api.get('/api/models');
@@ -57,14 +71,15 @@ function ProjectCreate() {
         name: values.name,
         description: values.description,
         vendors: values.vendors ? values.vendors.join(',') : '',
-        model: values.model
+        model: values.model,
+        organization_id: values.organization_id
       });
 
       if ((response as any).data?.success) {
         message.success('项目创建成功！');
         navigate('/projects');
       } else {
-        message.error('创建项目失败');
+        message.error((response as any).data?.error || '创建项目失败');
       }
     } catch (error) {
       console.error('Failed to create project:', error);
@@ -123,6 +138,20 @@ function ProjectCreate() {
                   />
                 </Form.Item>
 
+                <Form.Item
+                  label="所属组织"
+                  name="organization_id"
+                  rules={[
+                    { required: true, message: '请选择所属组织！' }
+                  ]}
+                >
+                  <Select
+                    placeholder="请选择所属组织"
+                    size="large"
+                    options={organizations.map((o: any) => ({ label: o.name, value: o.id }))}
+                  />
+                </Form.Item>
+
                 <Form.Item
                   label="参考厂商"
                   name="vendors"
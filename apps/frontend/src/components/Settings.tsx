@@ -113,14 +113,8 @@ function Settings() {
         setModels(serverModels);
       }
 
-      // Load model tokens from database (via serverModels response)
-      const tokensFromDB: Record<string, string> = {};
-      serverModels.forEach((model: any) => {
-        if (model.token) {
-          tokensFromDB[model.name] = model.token;
-        }
-      });
-      setModelTokens(tokensFromDB);
+      // Tokens are never returned by the server, only masked previews (token_preview)
+      setModelTokens({});
       
       if ((companiesRes as any).data?.success) {
         setCompanies((companiesRes as any).data.data);
@@ -448,7 +442,7 @@ function Settings() {
                     width: 200,
                     render: (_: any, record: any) => (
                       <Input.Password
-                        placeholder="输入该模型的API Token"
+                        placeholder={record.token_preview || "输入该模型的API Token"}
                         value={modelTokens[record.name] || ''}
                         onChange={async (e) => {
                           const tokenValue = e.target.value;
@@ -457,13 +451,7 @@ function Settings() {
                           
                           // Save to database via API
                           try {
-                            await fetch(`/api/models/${encodeURIComponent(record.name)}/token`, {
-                              method: 'PUT',
-                              headers: {
-                                'Content-Type': 'application/json',
-                              },
-                              body: JSON.stringify({ token: tokenValue }),
-                            });
+                            await api.put(`/api/models/${encodeURIComponent(record.name)}/token`, { token: tokenValue });
                           } catch (error) {
                             console.error('Failed to save token to database:', error);
                             // Fallback to localStorage for now
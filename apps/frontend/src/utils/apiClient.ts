@@ -42,6 +42,23 @@ export interface ApiResponse<T = any> {
   raw: Response;        // Raw fetch Response
 }
 
+// Tokens issued by /api/auth/login are kept in localStorage and sent as a Bearer header
+const ACCESS_TOKEN_KEY = 'neuro-accessToken';
+const REFRESH_TOKEN_KEY = 'neuro-refreshToken';
+
+export const authStore = {
+  getAccessToken: () => localStorage.getItem(ACCESS_TOKEN_KEY),
+  getRefreshToken: () => localStorage.getItem(REFRESH_TOKEN_KEY),
+  save(tokens: { access_token: string; refresh_token: string }) {
+    localStorage.setItem(ACCESS_TOKEN_KEY, tokens.access_token);
+    localStorage.setItem(REFRESH_TOKEN_KEY, tokens.refresh_token);
+  },
+  clear() {
+    localStorage.removeItem(ACCESS_TOKEN_KEY);
+    localStorage.removeItem(REFRESH_TOKEN_KEY);
+  }
+};
+
 function buildQuery(params?: Record<string, any> | URLSearchParams): string {
   if (!params) return '';
   if (params instanceof URLSearchParams) {
@@ -84,7 +101,32 @@ export class ApiClient {
     }
   }
 
-  async request<T = any>(path: string, method: HttpMethod, options: RequestOptions = {}): Promise<ApiResponse<T>> {
+  // refresh exchanges the refresh token for a new pair; returns false when the user must sign in again
+  private async refresh(): Promise<boolean> {
+    const refreshToken = authStore.getRefreshToken();
+    if (!refreshToken) return false;
+    const res = await this.request<any>('/api/auth/refresh', 'POST', { body: { refresh_token: refreshToken } }, false);
+    if (!res.ok || !res.data?.data?.access_token) return false;
+    authStore.save(res.data.data);
+    return true;
+  }
+
+  async request<T = any>(path: string, method: HttpMethod, options: RequestOptions = {}, retryAuth = true): Promise<ApiResponse<T>> {
+    const result = await this.send<T>(path, method, options);
+    if (result.status !== 401 || !retryAuth || path.startsWith('/api/auth/')) {
+      return result;
+    }
+    if (await this.refresh()) {
+      return this.send<T>(path, method, options);
+    }
+    authStore.clear();
+    if (window.location.pathname !== '/login') {
+      window.location.assign('/login');
+    }
+    return result;
+  }
+
+  private async send<T = any>(path: string, method: HttpMethod, options: RequestOptions = {}): Promise<ApiResponse<T>> {
     const { params, timeoutMs, successCheck, headers, body, ...rest } = options;
 
     const url = `${this.baseURL ? this.baseURL.replace(/\/$/, '') : ''}${path}${buildQuery(params)}`;
@@ -92,8 +134,10 @@ export class ApiClient {
     const controller = new AbortController();
     const timer = setTimeout(() => controller.abort(), timeoutMs ?? this.timeoutMs);
 
+    const accessToken = authStore.getAccessToken();
     const finalHeaders: HeadersInit = {
       ...(this.json ? { 'Content-Type': 'application/json' } : {}),
+      ...(accessToken ? { Authorization: `Bearer ${accessToken}` } : {}),
       ...this.defaultHeaders,
       ...(headers || {})
     };